@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// An Amount is a value in base units, Coin of them to a coin. It is 64 bits
+// wide on every platform.
+type Amount int64
+
+const (
+	CoinDecimals = 8
+	Coin         = Amount(100000000)
+
+	// MaxMoney is the most any output, or all outputs of a transaction
+	// together, may carry. It is a consensus rule.
+	MaxMoney = 21000000 * Coin
+)
+
+var ErrAmountOverflow = errors.New("amount out of range")
+
+// Coins converts a whole number of coins.
+func Coins(n int) Amount {
+	return Amount(n) * Coin
+}
+
+// Valid reports whether a is within the range consensus accepts.
+func (a Amount) Valid() bool {
+	return a >= 0 && a <= MaxMoney
+}
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrAmountOverflow
+	}
+	return a - b, nil
+}
+
+func (a Amount) Mul(n int64) (Amount, error) {
+	if a == 0 || n == 0 {
+		return 0, nil
+	}
+	product := a * Amount(n)
+	if product/Amount(n) != a || (a == -1 && n == math.MinInt64) || (n == -1 && a == math.MinInt64) {
+		return 0, ErrAmountOverflow
+	}
+	return product, nil
+}
+
+// SumAmounts adds amounts, failing if the total leaves the valid range.
+func SumAmounts(amounts ...Amount) (Amount, error) {
+	var total Amount
+	for _, amount := range amounts {
+		var err error
+		total, err = total.Add(amount)
+		if err != nil || !amount.Valid() || !total.Valid() {
+			return 0, fmt.Errorf("%w: the sum exceeds %s", ErrAmountOverflow, MaxMoney)
+		}
+	}
+	return total, nil
+}
+
+// feeRateAbove compares the rates a/aSize and b/bSize of non-negative fees
+// exactly, in 128 bits.
+func feeRateAbove(a Amount, aSize int, b Amount, bSize int) bool {
+	hiA, loA := bits.Mul64(uint64(a), uint64(bSize))
+	hiB, loB := bits.Mul64(uint64(b), uint64(aSize))
+	return hiA > hiB || (hiA == hiB && loA > loB)
+}
+
+// rateFor is the fee for size bytes at rate per 1000 bytes, rounded up.
+func rateFor(rate Amount, size int) Amount {
+	hi, lo := bits.Mul64(uint64(rate), uint64(size))
+	if hi >= 1000 {
+		return math.MaxInt64
+	}
+	quotient, remainder := bits.Div64(hi, lo, 1000)
+	if remainder > 0 {
+		quotient++
+	}
+	if quotient > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return Amount(quotient)
+}
+
+// String formats a as a decimal number of coins without trailing zeros.
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+	whole := units / uint64(Coin)
+	fraction := units % uint64(Coin)
+	if fraction == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%0*d", CoinDecimals, fraction), "0")
+	return fmt.Sprintf("%s%d.%s", sign, whole, digits)
+}
+
+// ParseAmount reads a non-negative decimal number of coins with at most
+// CoinDecimals places, such as "12" or "0.0005".
+func ParseAmount(s string) (Amount, error) {
+	whole, fraction, hasPoint := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || (hasPoint && fraction == "") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(fraction) > CoinDecimals {
+		return 0, fmt.Errorf("invalid amount %q: at most %d decimal places", s, CoinDecimals)
+	}
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	var coins, units uint64
+	var err error
+	if whole != "" {
+		coins, err = strconv.ParseUint(whole, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q: %w", s, ErrAmountOverflow)
+		}
+	}
+	if fraction != "" {
+		units, err = strconv.ParseUint(fraction+strings.Repeat("0", CoinDecimals-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	if coins > uint64(MaxMoney/Coin) {
+		return 0, fmt.Errorf("invalid amount %q: more than the %s coins that can exist", s, MaxMoney)
+	}
+	amount := Amount(coins)*Coin + Amount(units)
+	if amount > MaxMoney {
+		return 0, fmt.Errorf("invalid amount %q: more than the %s coins that can exist", s, MaxMoney)
+	}
+	return amount, nil
+}
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Amount
+		valid bool
+	}{
+		{"0", 0, true},
+		{"12", Coins(12), true},
+		{"0.5", Coin / 2, true},
+		{".5", Coin / 2, true},
+		{"0.00000001", 1, true},
+		{"1.23456789", 123456789, true},
+		{"21000000", MaxMoney, true},
+		{"21000000.00000001", 0, false},
+		{"99999999999999999999", 0, false},
+		{"0.000000001", 0, false},
+		{"", 0, false},
+		{".", 0, false},
+		{"1.", 0, false},
+		{"-1", 0, false},
+		{"1e3", 0, false},
+		{"1,5", 0, false},
+	}
+	for _, test := range tests {
+		got, err := ParseAmount(test.input)
+		if valid := err == nil; valid != test.valid || got != test.want {
+			t.Errorf("ParseAmount(%q) = %d, %v", test.input, got, err)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := map[Amount]string{
+		0:             "0",
+		Coins(3):      "3",
+		Coin / 2:      "0.5",
+		1:             "0.00000001",
+		-Coin - 1:     "-1.00000001",
+		MaxMoney:      "21000000",
+		123456789:     "1.23456789",
+		Coins(7) + 10: "7.0000001",
+	}
+	for amount, want := range tests {
+		if got := amount.String(); got != want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(amount), got, want)
+		}
+		if amount >= 0 {
+			if parsed, err := ParseAmount(want); err != nil || parsed != amount {
+				t.Errorf("ParseAmount(%q) = %d, %v, want %d", want, parsed, err, int64(amount))
+			}
+		}
+	}
+}
+
+func TestAmountArithmeticOverflows(t *testing.T) {
+	if _, err := Amount(math.MaxInt64).Add(1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MaxInt64 + 1: got %v", err)
+	}
+	if _, err := Amount(math.MinInt64).Sub(1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MinInt64 - 1: got %v", err)
+	}
+	if _, err := Amount(math.MaxInt64 / 2).Mul(3); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MaxInt64 / 2 * 3: got %v", err)
+	}
+	if got, err := Coin.Mul(21000000); err != nil || got != MaxMoney {
+		t.Errorf("Coin * 21000000 = %s, %v", got, err)
+	}
+
+	if total, err := SumAmounts(Coins(1), Coin/2); err != nil || total != Coins(1)+Coin/2 {
+		t.Errorf("SumAmounts(1, 0.5) = %s, %v", total, err)
+	}
+	for _, amounts := range [][]Amount{{MaxMoney, 1}, {-1}, {Coins(1), -1}, {math.MaxInt64, math.MaxInt64}} {
+		if _, err := SumAmounts(amounts...); !errors.Is(err, ErrAmountOverflow) {
+			t.Errorf("SumAmounts(%v): got %v", amounts, err)
+		}
+	}
+}
+
+func TestFeeRates(t *testing.T) {
+	if fee := rateFor(1000, 250); fee != 250 {
+		t.Errorf("250 bytes at 1000 per 1000 bytes: %d", fee)
+	}
+	if fee := rateFor(1, 250); fee != 1 {
+		t.Errorf("a fraction of a base unit is not rounded up: %d", fee)
+	}
+	if fee := rateFor(math.MaxInt64, math.MaxInt32); fee != math.MaxInt64 {
+		t.Errorf("an overflowing fee is %d, want it capped", fee)
+	}
+
+	// Products this large overflow 64 bits.
+	if !feeRateAbove(MaxMoney, 3, MaxMoney-1, 3) || feeRateAbove(MaxMoney-1, 3, MaxMoney, 3) {
+		t.Error("large fees are compared wrongly")
+	}
+	if feeRateAbove(200, 2000, 100, 1000) || feeRateAbove(100, 1000, 200, 2000) {
+		t.Error("equal rates compare as different")
+	}
+}
+
+func TestLegacyTransactionsStoreWholeCoins(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	output, err := NewTxOutput(Coins(5), address, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Transaction{Version: amountVersion - 1, Inputs: []TxInput{{ID: []byte("previous"), Out: 0}}, Outputs: []TxOutput{*output}}
+	if stored := legacy.encoded().Outputs[0].Value; stored != 5 {
+		t.Fatalf("a legacy output is stored as %d, want 5 whole coins", stored)
+	}
+	if decoded := DeserializeTransaction(legacy.Serialize()); decoded.Outputs[0].Value != Coins(5) {
+		t.Fatalf("a legacy output reads back as %s, want 5", decoded.Outputs[0].Value)
+	}
+
+	current := *legacy
+	current.Version = TxVersion
+	if decoded := DeserializeTransaction(current.Serialize()); decoded.Outputs[0].Value != Coins(5) {
+		t.Fatalf("an output reads back as %s, want 5", decoded.Outputs[0].Value)
+	}
+	if current.encoded().Outputs[0].Value != Coins(5) {
+		t.Fatal("a current output is not stored in base units")
+	}
+}
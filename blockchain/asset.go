@@ -0,0 +1,396 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"unicode/utf8"
+
+	"golang-blockchain/wallet"
+)
+
+const (
+	assetIDLength      = 32
+	maxAssetNameLength = 32
+)
+
+// An AssetIssuance creates Amount units of an asset, paid to the outputs of
+// the transaction carrying its ID. A new asset takes its ID from the outpoint
+// the first input spends, which fixes its name, whether more can be minted
+// and the issuer's lock. Minting more of a mintable asset names only the
+// asset and the amount, and one of the inputs has to spend an output locked
+// to the issuer.
+type AssetIssuance struct {
+	Asset         []byte
+	Name          string
+	Amount        Amount
+	Mintable      bool
+	IssuerKeyType wallet.KeyType
+	IssuerHash    []byte
+}
+
+// An Asset is an asset issued in the chain with the supply minted so far.
+type Asset struct {
+	ID            []byte
+	Name          string
+	Mintable      bool
+	IssuerKeyType wallet.KeyType
+	IssuerHash    []byte
+	Supply        Amount
+	IssueTx       []byte
+}
+
+// AssetID derives the ID of an asset issued by a transaction whose first
+// input is input. An outpoint is spent once, so the ID is unique.
+func AssetID(input TxInput) []byte {
+	data := append([]byte("asset"), input.ID...)
+	hash := sha256.Sum256(binary.BigEndian.AppendUint32(data, uint32(input.Out)))
+	return hash[:]
+}
+
+func (t *Transaction) issuesNewAsset() bool {
+	return t.Issuance != nil && len(t.Inputs) > 0 && bytes.Equal(t.Issuance.Asset, AssetID(t.Inputs[0]))
+}
+
+// checkAssets checks the asset fields without looking at the chain.
+func (t *Transaction) checkAssets() error {
+	issuance := t.Issuance
+	carries := issuance != nil
+	for _, output := range t.Outputs {
+		if len(output.Asset) > 0 || output.AssetAmount != 0 {
+			carries = true
+		}
+	}
+	if !carries {
+		return nil
+	}
+	if t.IsCoinbase() {
+		return fmt.Errorf("coinbase %x cannot issue or carry assets", t.ID)
+	}
+	if t.Version < assetVersion {
+		return fmt.Errorf("transaction %x has version %d, assets need version %d", t.ID, t.Version, assetVersion)
+	}
+
+	totals := make(map[string]Amount)
+	for i, output := range t.Outputs {
+		if len(output.Asset) == 0 {
+			if output.AssetAmount != 0 {
+				return fmt.Errorf("output %d of %x carries %s of no asset", i, t.ID, output.AssetAmount)
+			}
+			continue
+		}
+		if len(output.Asset) != assetIDLength {
+			return fmt.Errorf("output %d of %x carries an asset with a %d byte ID", i, t.ID, len(output.Asset))
+		}
+		if output.AssetAmount <= 0 || output.AssetAmount > MaxMoney {
+			return fmt.Errorf("output %d of %x carries %s of asset %x, out of range", i, t.ID, output.AssetAmount, output.Asset)
+		}
+		id := hex.EncodeToString(output.Asset)
+		total, err := SumAmounts(totals[id], output.AssetAmount)
+		if err != nil {
+			return fmt.Errorf("the outputs of %x carry asset %x: %w", t.ID, output.Asset, err)
+		}
+		totals[id] = total
+	}
+
+	if issuance == nil {
+		return nil
+	}
+	if !issuance.Amount.Valid() {
+		return fmt.Errorf("transaction %x issues %s, out of range", t.ID, issuance.Amount)
+	}
+	if !t.issuesNewAsset() {
+		if len(issuance.Asset) != assetIDLength {
+			return fmt.Errorf("transaction %x issues an asset with a %d byte ID", t.ID, len(issuance.Asset))
+		}
+		if issuance.Name != "" || issuance.Mintable || issuance.IssuerHash != nil {
+			return fmt.Errorf("transaction %x mints more of asset %x but redefines it", t.ID, issuance.Asset)
+		}
+		if issuance.Amount == 0 {
+			return fmt.Errorf("transaction %x mints nothing of asset %x", t.ID, issuance.Asset)
+		}
+		return nil
+	}
+	if issuance.Name == "" || len(issuance.Name) > maxAssetNameLength || !utf8.ValidString(issuance.Name) {
+		return fmt.Errorf("transaction %x names its asset %q, names are 1 to %d bytes of UTF-8", t.ID, issuance.Name, maxAssetNameLength)
+	}
+	if issuance.Amount == 0 && !issuance.Mintable {
+		return fmt.Errorf("transaction %x issues a fixed supply of nothing", t.ID)
+	}
+	if size := len(issuance.IssuerHash); issuance.Mintable && size != 20 && size != 32 {
+		return fmt.Errorf("transaction %x issues a mintable asset to a %d byte issuer hash", t.ID, size)
+	}
+	return nil
+}
+
+// checkAssetBalances requires every asset to leave a transaction in the
+// amounts it came in with, plus what the transaction issues. Fees are paid
+// in coins only, so nothing of an asset is destroyed.
+func (t *Transaction) checkAssetBalances(spent []TxOutput) error {
+	balances := make(map[string]Amount)
+	for _, output := range spent {
+		if len(output.Asset) > 0 {
+			balances[hex.EncodeToString(output.Asset)] += output.AssetAmount
+		}
+	}
+	if t.Issuance != nil {
+		balances[hex.EncodeToString(t.Issuance.Asset)] += t.Issuance.Amount
+	}
+	for _, output := range t.Outputs {
+		if len(output.Asset) > 0 {
+			balances[hex.EncodeToString(output.Asset)] -= output.AssetAmount
+		}
+	}
+
+	for id, balance := range balances {
+		if balance > 0 {
+			return fmt.Errorf("transaction %x would destroy %s of asset %s", t.ID, balance, id)
+		}
+		if balance < 0 {
+			return fmt.Errorf("transaction %x pays out %s of asset %s it neither spends nor issues", t.ID, -balance, id)
+		}
+	}
+	return nil
+}
+
+// checkMint allows minting more of an asset only when the chain issued it
+// as mintable and an input spends an output locked to its issuer. Mints
+// still pending, earlier in the block or in the mempool, count towards the
+// supply, except those t conflicts with and would replace.
+func (chain *BlockChain) checkMint(t *Transaction, previousTxs map[string]Transaction, pending map[string]*Transaction) error {
+	if t.Issuance == nil || t.issuesNewAsset() {
+		return nil
+	}
+	id := hex.EncodeToString(t.Issuance.Asset)
+
+	spends := make(map[string]bool)
+	for _, input := range t.Inputs {
+		spends[outpoint(input.ID, input.Out)] = true
+	}
+	var issues, mints []*Transaction
+Pending:
+	for _, tx := range pending {
+		if tx.Issuance == nil || hex.EncodeToString(tx.Issuance.Asset) != id {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if spends[outpoint(input.ID, input.Out)] {
+				continue Pending
+			}
+		}
+		if tx.issuesNewAsset() {
+			issues = append(issues, tx)
+		} else {
+			mints = append(mints, tx)
+		}
+	}
+
+	assets := chain.Assets()
+	asset := assets[id]
+	if asset == nil && len(issues) > 0 {
+		err := addIssuances(assets, issues)
+		if err != nil {
+			return err
+		}
+		asset = assets[id]
+	}
+	if asset == nil {
+		return fmt.Errorf("transaction %x mints asset %x, which the chain has not issued", t.ID, t.Issuance.Asset)
+	}
+	if !asset.Mintable {
+		return fmt.Errorf("transaction %x mints asset %x, which has a fixed supply", t.ID, asset.ID)
+	}
+	if err := addIssuances(assets, append(mints, t)); err != nil {
+		return fmt.Errorf("transaction %x mints asset %x: %w", t.ID, asset.ID, err)
+	}
+
+	spent, err := t.spentOutputs(previousTxs)
+	if err != nil {
+		return err
+	}
+	for _, output := range spent {
+		if output.KeyType == asset.IssuerKeyType && bytes.Equal(output.PubKeyHash, asset.IssuerHash) {
+			return nil
+		}
+	}
+	return fmt.Errorf("transaction %x mints asset %x without spending an output of its issuer", t.ID, asset.ID)
+}
+
+// Assets lists the assets issued in the chain by hex ID.
+func (chain *BlockChain) Assets() map[string]*Asset {
+	var issuances []*Transaction
+	iterator := chain.Iterator()
+
+	for {
+		bloco := iterator.Next()
+
+		for i := len(bloco.Transactions) - 1; i >= 0; i-- {
+			if bloco.Transactions[i].Issuance != nil {
+				issuances = append(issuances, bloco.Transactions[i])
+			}
+		}
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+
+	// Oldest first, so an asset exists before anything mints more of it.
+	for i, j := 0, len(issuances)-1; i < j; i, j = i+1, j-1 {
+		issuances[i], issuances[j] = issuances[j], issuances[i]
+	}
+	assets := make(map[string]*Asset)
+	Handle(addIssuances(assets, issuances))
+	return assets
+}
+
+// addIssuances applies issuances to assets in order, failing when a supply
+// would overflow.
+func addIssuances(assets map[string]*Asset, issuances []*Transaction) error {
+	for _, tx := range issuances {
+		issuance := tx.Issuance
+		id := hex.EncodeToString(issuance.Asset)
+		if tx.issuesNewAsset() {
+			assets[id] = &Asset{
+				ID:            issuance.Asset,
+				Name:          issuance.Name,
+				Mintable:      issuance.Mintable,
+				IssuerKeyType: issuance.IssuerKeyType,
+				IssuerHash:    issuance.IssuerHash,
+				Supply:        issuance.Amount,
+				IssueTx:       tx.ID,
+			}
+		} else if asset := assets[id]; asset != nil {
+			supply, err := SumAmounts(asset.Supply, issuance.Amount)
+			if err != nil {
+				return err
+			}
+			asset.Supply = supply
+		}
+	}
+	return nil
+}
+
+// changeTo returns the change address, asking changeAddress for it once at
+// most and falling back to from.
+func changeTo(from string, changeAddress func() string) func() string {
+	address := ""
+	return func() string {
+		if address == "" {
+			address = from
+			if changeAddress != nil {
+				address = changeAddress()
+			}
+		}
+		return address
+	}
+}
+
+// newOutput locks value to address, which the caller has checked already.
+func (chain *BlockChain) newOutput(value Amount, address string) TxOutput {
+	output, err := NewTxOutput(value, address, chain.Params)
+	Handle(err)
+	return *output
+}
+
+func (chain *BlockChain) assetOutput(value Amount, address string, asset []byte, amount Amount) TxOutput {
+	output := chain.newOutput(value, address)
+	output.Asset = asset
+	output.AssetAmount = amount
+	return output
+}
+
+func (chain *BlockChain) newAssetTransaction(inputs []TxInput, outputs []TxOutput, issuance *AssetIssuance) *Transaction {
+	if len(inputs) > chain.Params.MaxTxInputs {
+		log.Panicf("Error: this transaction needs %d inputs, the limit is %d", len(inputs), chain.Params.MaxTxInputs)
+	}
+	transaction := Transaction{
+		Inputs:   inputs,
+		Outputs:  outputs,
+		Version:  TxVersion,
+		Issuance: issuance,
+	}
+	transaction.ID = transaction.Hash()
+	return &transaction
+}
+
+// NewIssuanceTransaction issues a new asset to from, or mints more of a
+// mintable one when issuance names it. The issued output carries dust coins
+// so it is relayed, they come out of from's balance with the fee.
+func NewIssuanceTransaction(from string, publicKey []byte, issuance AssetIssuance, fee, dust Amount, replaceable bool, changeAddress func() string, chain *BlockChain) *Transaction {
+	addr, publicKey := spendingKey(from, publicKey, chain.Params)
+	newAsset := issuance.Asset == nil
+	if newAsset {
+		issuance.IssuerKeyType = addr.Key
+		issuance.IssuerHash = addr.Hash
+	}
+
+	need := fee
+	if issuance.Amount > 0 {
+		var err error
+		need, err = SumAmounts(fee, dust)
+		Handle(err)
+	}
+	saldo, validOutputs := chain.FindSpendableOutputs(addr.Hash, need)
+	if saldo < need || len(validOutputs) == 0 {
+		fmt.Printf("O usuario so tem %s de saldo", saldo)
+		log.Panic("Error: not enough funds for this transaction")
+	}
+	inputs := spendingInputs(validOutputs, publicKey, replaceable)
+	if newAsset {
+		issuance.Asset = AssetID(inputs[0])
+	}
+
+	var outputs []TxOutput
+	if issuance.Amount > 0 {
+		outputs = append(outputs, chain.assetOutput(dust, from, issuance.Asset, issuance.Amount))
+	}
+	if saldo > need {
+		outputs = append(outputs, chain.newOutput(saldo-need, changeTo(from, changeAddress)()))
+	}
+	return chain.newAssetTransaction(inputs, outputs, &issuance)
+}
+
+// NewAssetTransaction sends amount of asset to to. Each asset output carries
+// dust coins, which together with the fee come first out of the coins the
+// spent asset outputs carried and then out of from's balance.
+func NewAssetTransaction(from string, publicKey []byte, to string, asset []byte, amount, fee, dust Amount, replaceable bool, changeAddress func() string, chain *BlockChain) *Transaction {
+	addr, publicKey := spendingKey(from, publicKey, chain.Params)
+	change := changeTo(from, changeAddress)
+
+	held, assetOutputs := chain.FindSpendableAssetOutputs(addr.Hash, asset, amount)
+	if held < amount {
+		fmt.Printf("O usuario so tem %s do ativo %x", held, asset)
+		log.Panic("Error: not enough of the asset for this transaction")
+	}
+	inputs := spendingInputs(assetOutputs, publicKey, replaceable)
+	spent, err := chain.SpentOutputs(&Transaction{Inputs: inputs})
+	Handle(err)
+	carried, err := sumValues(spent)
+	Handle(err)
+
+	outputs := []TxOutput{chain.assetOutput(dust, to, asset, amount)}
+	if held > amount {
+		outputs = append(outputs, chain.assetOutput(dust, change(), asset, held-amount))
+	}
+	need, err := dust.Mul(int64(len(outputs)))
+	Handle(err)
+	need, err = SumAmounts(need, fee)
+	Handle(err)
+
+	if carried < need {
+		saldo, validOutputs := chain.FindSpendableOutputs(addr.Hash, need-carried)
+		if saldo < need-carried {
+			fmt.Printf("O usuario so tem %s de saldo", carried+saldo)
+			log.Panic("Error: not enough funds for this transaction")
+		}
+		inputs = append(inputs, spendingInputs(validOutputs, publicKey, replaceable)...)
+		carried += saldo
+	}
+	if carried > need {
+		outputs = append(outputs, chain.newOutput(carried-need, change()))
+	}
+	return chain.newAssetTransaction(inputs, outputs, nil)
+}
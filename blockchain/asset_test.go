@@ -0,0 +1,167 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang-blockchain/wallet"
+)
+
+const testDust = 546
+
+// issue signs a transaction issuing or minting an asset to address.
+func issue(chain *BlockChain, w *wallet.Wallet, address string, issuance AssetIssuance) *Transaction {
+	tx := NewIssuanceTransaction(address, w.PublicKey, issuance, 10000, testDust, false, nil, chain)
+	chain.SignTransaction(tx, *w, SigHashAll)
+	return tx
+}
+
+func TestIssueFixedAsset(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+
+	tx := issue(chain, w, address, AssetIssuance{Name: "gold", Amount: 1000})
+	accept(t, chain, tx)
+	chain.Generate(address, 1)
+
+	asset := chain.Assets()[hex.EncodeToString(tx.Issuance.Asset)]
+	if asset == nil {
+		t.Fatal("the issued asset is not in the chain")
+	}
+	if asset.Name != "gold" || asset.Supply != 1000 || asset.Mintable {
+		t.Fatalf("asset is %q with supply %s, mintable %t", asset.Name, asset.Supply, asset.Mintable)
+	}
+
+	mint := issue(chain, w, address, AssetIssuance{Asset: asset.ID, Amount: 1})
+	expectReject(t, chain, mint, "fixed supply")
+}
+
+func TestMintAsset(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+
+	tx := issue(chain, w, address, AssetIssuance{Name: "silver", Amount: 1000, Mintable: true})
+	accept(t, chain, tx)
+	chain.Generate(address, 1)
+	asset := tx.Issuance.Asset
+
+	mint := issue(chain, w, address, AssetIssuance{Asset: asset, Amount: 500})
+	accept(t, chain, mint)
+	chain.Generate(address, 1)
+
+	if supply := chain.Assets()[hex.EncodeToString(asset)].Supply; supply != 1500 {
+		t.Fatalf("supply is %s after minting, want 1500 units", supply)
+	}
+	if balance := assetBalance(chain, address, asset); balance != 1500 {
+		t.Fatalf("issuer holds %s of the asset, want 1500 units", balance)
+	}
+}
+
+func TestOverMintCountsPendingMints(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 3)
+
+	tx := issue(chain, w, address, AssetIssuance{Name: "silver", Amount: MaxMoney - 10, Mintable: true})
+	accept(t, chain, tx)
+	chain.Generate(address, 1)
+	asset := tx.Issuance.Asset
+
+	first := issue(chain, w, address, AssetIssuance{Asset: asset, Amount: 6})
+	accept(t, chain, first)
+
+	// Each mint fits on its own, together they pass the limit.
+	second := issue(chain, w, address, AssetIssuance{Asset: asset, Amount: 6})
+	expectReject(t, chain, second, "invalid")
+
+	coinbase := coinbaseTx(t, chain, address, "over-mint")
+	if err := chain.AddBlock([]*Transaction{coinbase, first, second}); err == nil {
+		t.Fatal("a block minting past the supply limit was added")
+	}
+
+	chain.Generate(address, 1)
+	if supply := chain.Assets()[hex.EncodeToString(asset)].Supply; supply != MaxMoney-4 {
+		t.Fatalf("supply is %s, want %s", supply, MaxMoney-4)
+	}
+}
+
+func assetBalance(chain *BlockChain, address string, asset []byte) Amount {
+	var balance Amount
+	for _, output := range chain.FindUTXO(chain.newOutput(0, address).PubKeyHash) {
+		if bytes.Equal(output.Asset, asset) {
+			balance += output.AssetAmount
+		}
+	}
+	return balance
+}
+
+func TestSendAsset(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	other := wallet.MakeWallet(wallet.P256)
+	to := string(other.Address(chain.Params.AddressVersion))
+
+	issued := issue(chain, w, address, AssetIssuance{Name: "gold", Amount: 1000})
+	accept(t, chain, issued)
+	chain.Generate(address, 1)
+	asset := issued.Issuance.Asset
+
+	tx := NewAssetTransaction(address, w.PublicKey, to, asset, 300, 10000, testDust, false, nil, chain)
+	chain.SignTransaction(tx, *w, SigHashAll)
+	accept(t, chain, tx)
+	chain.Generate(address, 1)
+
+	if balance := assetBalance(chain, to, asset); balance != 300 {
+		t.Fatalf("recipient holds %s of the asset, want 300 units", balance)
+	}
+	if balance := assetBalance(chain, address, asset); balance != 700 {
+		t.Fatalf("sender holds %s of the asset, want 700 units", balance)
+	}
+
+	// The dust carried by asset outputs is not spent as plain coins.
+	_, outputs := chain.FindSpendableOutputs(wallet.PublicKeyHash(w.PublicKey), MaxMoney)
+	for id, indexes := range outputs {
+		txID, _ := hex.DecodeString(id)
+		previous, err := chain.FindTransaction(txID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range indexes {
+			if len(previous.Outputs[i].Asset) > 0 {
+				t.Fatalf("output %s:%d carrying an asset was selected to pay coins", id, i)
+			}
+		}
+	}
+}
+
+func TestAssetsAreConserved(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	issued := issue(chain, w, address, AssetIssuance{Name: "gold", Amount: 1000})
+	accept(t, chain, issued)
+	chain.Generate(address, 1)
+	asset := issued.Issuance.Asset
+
+	for _, change := range []Amount{1, -1} {
+		tx := NewAssetTransaction(address, w.PublicKey, address, asset, 1000, 10000, testDust, false, nil, chain)
+		tx.Outputs[0].AssetAmount += change
+		tx.ID = tx.Hash()
+		chain.SignTransaction(tx, *w, SigHashAll)
+		expectReject(t, chain, tx, "of asset")
+	}
+}
+
+func TestOnlyTheIssuerMints(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	other := wallet.MakeWallet(wallet.P256)
+	otherAddress := string(other.Address(chain.Params.AddressVersion))
+	chain.Generate(address, 1)
+	chain.Generate(otherAddress, 2)
+
+	issued := issue(chain, w, address, AssetIssuance{Name: "silver", Amount: 1000, Mintable: true})
+	accept(t, chain, issued)
+	chain.Generate(address, 1)
+
+	mint := issue(chain, other, otherAddress, AssetIssuance{Asset: issued.Issuance.Asset, Amount: 1})
+	expectReject(t, chain, mint, "without spending an output of its issuer")
+}
@@ -4,41 +4,55 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/gob"
+	"time"
 )
 
 type Block struct {
+	Timestamp    int64
 	Hash         []byte
 	Transactions []*Transaction
 	PrevHash     []byte
 	Nonce        int
+	Height       int
 }
 
-func CreateBlock(trans []*Transaction, prevHash []byte) *Block {
+func CreateBlock(trans []*Transaction, prevHash []byte, height int, timestamp int64, difficulty, threads int) *Block {
 	block := &Block{
+		timestamp,
 		[]byte{},
 		trans,
 		prevHash,
 		0,
+		height,
 	}
-	pow := NewProof(block)
-	nonce, hash := pow.Run()
+	pow := NewProof(block, difficulty)
+	nonce, hash := pow.Run(threads)
 	block.Hash = hash[:]
 	block.Nonce = nonce
 
 	return block
 }
 
-func Genesis(coinbase *Transaction) *Block {
+func Genesis(coinbase *Transaction, difficulty, threads int) *Block {
 	return CreateBlock(
 		[]*Transaction{coinbase},
 		[]byte{},
+		0,
+		time.Now().Unix(),
+		difficulty,
+		threads,
 	)
 }
 
 func (b *Block) Serialize() []byte {
 	var result bytes.Buffer
+	stored := *b
+	stored.Transactions = make([]*Transaction, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		stored.Transactions[i] = tx.encoded()
+	}
 	encod := gob.NewEncoder(&result)
-	err := encod.Encode(b)
+	err := encod.Encode(&stored)
 	Handle(err)
 
 	return result.Bytes()
@@ -49,6 +63,9 @@ func Deserialize(data []byte) *Block {
 	decod := gob.NewDecoder(bytes.NewReader(data))
 	err := decod.Decode(&block)
 	Handle(err)
+	for _, tx := range block.Transactions {
+		tx.decoded()
+	}
 
 	return &block
 }
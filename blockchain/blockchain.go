@@ -1,25 +1,37 @@
 package blockchain
 
 import (
-	"crypto/ecdsa"
+	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"runtime"
+	"sort"
+	"time"
+
+	"golang-blockchain/params"
+	"golang-blockchain/wallet"
 
 	"github.com/dgraph-io/badger"
 )
 
 const (
-	dbPath      = "./tmp/blocks"
-	dbFile      = "./tmp/blocks/MANIFEST"
-	genesisData = "Genesis Block Data"
+	medianTimeBlocks   = 11
+	maxFutureBlockTime = 2 * time.Hour
 )
 
 type BlockChain struct {
 	LastHash []byte
 	Database *badger.DB
+	Params   *params.Params
+}
+
+type HistoryEntry struct {
+	ID        []byte
+	Height    int
+	Timestamp int64
+	Received  Amount
+	Sent      Amount
 }
 
 type BlockChainIterator struct {
@@ -27,46 +39,58 @@ type BlockChainIterator struct {
 	Database    *badger.DB
 }
 
-func InitBlockChain(address string) *BlockChain {
+func InitBlockChain(address string, p *params.Params) *BlockChain {
 	var lastHash []byte
-	if DBExists() {
+	if DBExists(p) {
 		fmt.Printf("BlockChain already crated, skipping")
 		runtime.Goexit()
 	}
 
-	opts := badger.DefaultOptions(dbPath)
+	opts := badger.DefaultOptions(p.DBPath())
 	db, err := badger.Open(opts)
 	Handle(err)
 
 	err = db.Update(func(txn *badger.Txn) error {
-		coinbaseTransaction := CoinbaseTx(address, genesisData)
-		genesis := Genesis(coinbaseTransaction)
+		coinbaseTransaction, err := CoinbaseTx(address, p.GenesisMessage, Coins(p.Reward), p)
+		Handle(err)
+		genesis := Genesis(coinbaseTransaction, p.Difficulty, p.MinerThreads)
 		fmt.Println("Genesis Block created successfully")
 		err = txn.Set(genesis.Hash, genesis.Serialize())
 		Handle(err)
+		err = txn.Set([]byte("magic"), p.Magic[:])
+		Handle(err)
 		err = txn.Set([]byte("lh"), genesis.Hash)
 		lastHash = genesis.Hash
 		return err
 	})
 	Handle(err)
-	chain := &BlockChain{LastHash: lastHash, Database: db}
+	chain := &BlockChain{LastHash: lastHash, Database: db, Params: p}
 	return chain
 }
 
-func ContinueBlockChain(address string) *BlockChain {
-	if DBExists() == false {
+func ContinueBlockChain(address string, p *params.Params) *BlockChain {
+	if DBExists(p) == false {
 		fmt.Println("No existing blockchain database found, create a new one first")
 		runtime.Goexit()
 	}
 
-	opts := badger.DefaultOptions(dbPath)
+	opts := badger.DefaultOptions(p.DBPath())
 	opts.Logger = nil
 	db, err := badger.Open(opts)
 	Handle(err)
 
 	var lastHash []byte
+	var magic []byte
 	err = db.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("lh"))
+		item, err := txn.Get([]byte("magic"))
+		if err == nil {
+			magic, err = item.ValueCopy(nil)
+			Handle(err)
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		item, err = txn.Get([]byte("lh"))
 		Handle(err)
 		err = item.Value(func(val []byte) error {
 			lastHash = append([]byte{}, val...) // Make a copy of the value
@@ -74,32 +98,67 @@ func ContinueBlockChain(address string) *BlockChain {
 		})
 		return err
 	})
+	Handle(err)
+
+	if magic != nil && !bytes.Equal(magic, p.Magic[:]) {
+		db.Close()
+		fmt.Printf("Database in %s does not belong to the %s network\n", p.DBPath(), p.Name)
+		runtime.Goexit()
+	}
 
-	chain := &BlockChain{LastHash: lastHash, Database: db}
+	chain := &BlockChain{LastHash: lastHash, Database: db, Params: p}
 	return chain
 
 }
 
 func (chain *BlockChain) AddBlock(transactions []*Transaction) error {
 	var lastHash []byte
+	var lastHeight int
 
-	for _, tx := range transactions {
-		if chain.VerifyTransaction(tx) != true {
-			log.Panic("Invalid Transaction")
-		}
+	batch := new(wallet.SchnorrBatch)
+	if err := chain.verifyBlockTransactions(transactions, batch); err != nil {
+		return err
+	}
+	if err := batch.Verify(); err != nil {
+		return fmt.Errorf("%w: schnorr signature %d of %d in the block is invalid", err, batch.Failed()+1, batch.Len())
 	}
 
 	err := chain.Database.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte("lh"))
 		Handle(err)
-		return item.Value(func(val []byte) error {
-			lastHash = val
-			return nil
-		})
+		lastHash, err = item.ValueCopy(nil)
+		Handle(err)
+
+		item, err = txn.Get(lastHash)
+		Handle(err)
+		lastBlockData, err := item.ValueCopy(nil)
+		Handle(err)
+		lastHeight = Deserialize(lastBlockData).Height
+		return nil
 	})
 	Handle(err)
 
-	newBlock := CreateBlock(transactions, lastHash)
+	timestamp := time.Now().Unix()
+	if medianTime := chain.MedianTimePast(lastHash); timestamp <= medianTime {
+		timestamp = medianTime + 1
+	}
+
+	template := &Block{Timestamp: timestamp, Transactions: transactions, PrevHash: lastHash, Height: lastHeight + 1}
+	err = chain.ValidateBlockLimits(template)
+	if err != nil {
+		return err
+	}
+
+	newBlock := CreateBlock(transactions, lastHash, lastHeight+1, timestamp, chain.Params.Difficulty, chain.Params.MinerThreads)
+	err = chain.ValidateTimestamp(newBlock)
+	if err != nil {
+		return err
+	}
+	err = chain.ValidateBlockLimits(newBlock)
+	if err != nil {
+		return err
+	}
+
 	err = chain.Database.Update(func(txn *badger.Txn) error {
 		err := txn.Set(newBlock.Hash, newBlock.Serialize())
 		Handle(err)
@@ -107,7 +166,149 @@ func (chain *BlockChain) AddBlock(transactions []*Transaction) error {
 		chain.LastHash = newBlock.Hash
 		return err
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return chain.removeConfirmed(newBlock)
+}
+
+// verifyBlockTransactions checks every transaction of a new block. Only the
+// first may be a coinbase and it may pay out no more than the reward and the
+// fees of the others. No output may be spent twice, whether in the chain
+// already or within the block.
+func (chain *BlockChain) verifyBlockTransactions(transactions []*Transaction, batch *wallet.SchnorrBatch) error {
+	spent := chain.spentOutpoints()
+	inBlock := make(map[string]bool)
+	earlier := make(map[string]*Transaction)
+	var coinbase *Transaction
+	var fees Amount
+
+	for i, tx := range transactions {
+		if tx.IsCoinbase() {
+			if i != 0 {
+				return fmt.Errorf("coinbase %x is not the first transaction of the block", tx.ID)
+			}
+			coinbase = tx
+			earlier[hex.EncodeToString(tx.ID)] = tx
+			continue
+		}
+		if err := chain.verifyTransaction(tx, batch, earlier); err != nil {
+			return fmt.Errorf("transaction %x in the block is invalid: %w", tx.ID, err)
+		}
+		previous := make(map[string]Transaction)
+		for _, input := range tx.Inputs {
+			id := hex.EncodeToString(input.ID)
+			if parent, ok := earlier[id]; ok {
+				previous[id] = *parent
+			} else {
+				previous[id], _ = chain.FindTransaction(input.ID)
+			}
+			point := outpoint(input.ID, input.Out)
+			if spent[point] {
+				return fmt.Errorf("transaction %x spends %s, which is already spent in the chain", tx.ID, point)
+			}
+			if inBlock[point] {
+				return fmt.Errorf("transaction %x spends %s, which is spent twice in the block", tx.ID, point)
+			}
+			inBlock[point] = true
+		}
+		fees += tx.InputValue(previous) - tx.OutputValue()
+		earlier[hex.EncodeToString(tx.ID)] = tx
+	}
+
+	if coinbase != nil {
+		if paid, limit := coinbase.OutputValue(), Coins(chain.Params.Reward)+fees; paid > limit {
+			return fmt.Errorf("coinbase %x pays %s, the reward and fees of the block are %s", coinbase.ID, paid, limit)
+		}
+	}
+	return nil
+}
+
+func (chain *BlockChain) Generate(address string, count int) []*Block {
+	var blocks []*Block
+
+	for i := 0; i < count; i++ {
+		height := chain.GetBestHeight() + 1
+		data := fmt.Sprintf("Coins to %s at height %d", address, height)
+		transactions, fees := chain.BlockTemplate()
+		coinbase, err := CoinbaseTx(address, data, Coins(chain.Params.Reward)+fees, chain.Params)
+		Handle(err)
+		err = chain.AddBlock(append([]*Transaction{coinbase}, transactions...))
+		Handle(err)
+		blocks = append(blocks, chain.GetBlock(chain.LastHash))
+	}
+	return blocks
+}
+
+func (chain *BlockChain) GetBlock(hash []byte) *Block {
+	var blockData []byte
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(hash)
+		if err != nil {
+			return err
+		}
+		blockData, err = item.ValueCopy(nil)
+		return err
+	})
+	Handle(err)
+	return Deserialize(blockData)
+}
+
+func (chain *BlockChain) MedianTimePast(hash []byte) int64 {
+	var timestamps []int64
+	iterator := &BlockChainIterator{CurrentHash: hash, Database: chain.Database}
+
+	for len(timestamps) < medianTimeBlocks {
+		bloco := iterator.Next()
+		timestamps = append(timestamps, bloco.Timestamp)
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+func (chain *BlockChain) ValidateTimestamp(block *Block) error {
+	medianTime := chain.MedianTimePast(block.PrevHash)
+	if block.Timestamp <= medianTime {
+		return fmt.Errorf("block timestamp %d is not after the median time past %d", block.Timestamp, medianTime)
+	}
+	maxTime := time.Now().Add(maxFutureBlockTime).Unix()
+	if block.Timestamp > maxTime {
+		return fmt.Errorf("block timestamp %d is too far in the future", block.Timestamp)
+	}
+	return nil
+}
+
+func (chain *BlockChain) GetBestHeight() int {
+	return chain.GetBlock(chain.LastHash).Height
+}
+
+func (chain *BlockChain) immatureCoinbases() map[string]bool {
+	immature := make(map[string]bool)
+	nextHeight := chain.GetBestHeight() + 1
+	iterator := chain.Iterator()
+
+	for {
+		bloco := iterator.Next()
+		// Blocks stored before heights existed read as height 0 past the
+		// genesis block, they and everything before them are long buried.
+		legacy := bloco.Height == 0 && len(bloco.PrevHash) > 0
+		if legacy || nextHeight-bloco.Height >= chain.Params.CoinbaseMaturity {
+			break
+		}
+		for _, tx := range bloco.Transactions {
+			if tx.IsCoinbase() {
+				immature[hex.EncodeToString(tx.ID)] = true
+			}
+		}
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+	return immature
 }
 
 func (chain *BlockChain) Iterator() *BlockChainIterator {
@@ -134,22 +335,34 @@ func (iter *BlockChainIterator) Next() *Block {
 	return block
 }
 
-func DBExists() bool {
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+func DBExists(p *params.Params) bool {
+	if _, err := os.Stat(p.DBFile()); os.IsNotExist(err) {
 		return false
 	}
 	return true
 }
 
 func (chain *BlockChain) FindUnspentTransaction(publicKeyHash []byte) []Transaction {
+	unspentTransactions, _ := chain.findUnspent(publicKeyHash)
+	return unspentTransactions
+}
+
+// findUnspent lists each transaction with an unspent output locked to
+// publicKeyHash once, together with the outpoints of publicKeyHash already
+// spent, as a transaction can pay one key several outputs.
+func (chain *BlockChain) findUnspent(publicKeyHash []byte) ([]Transaction, map[string]bool) {
 	var unspentTransactions []Transaction
 	spentTransaction := make(map[string][]int)
+	spent := make(map[string]bool)
 	iterator := chain.Iterator()
 
 	for {
 		bloco := iterator.Next()
 
-		for _, tx := range bloco.Transactions {
+		// Walk the block backwards too, a transaction can spend an
+		// earlier one of the same block.
+		for i := len(bloco.Transactions) - 1; i >= 0; i-- {
+			tx := bloco.Transactions[i]
 			id := hex.EncodeToString(tx.ID)
 
 		Outputs:
@@ -163,6 +376,7 @@ func (chain *BlockChain) FindUnspentTransaction(publicKeyHash []byte) []Transact
 				}
 				if output.IsLocked(publicKeyHash) {
 					unspentTransactions = append(unspentTransactions, *tx)
+					break
 				}
 			}
 			if tx.IsCoinbase() == false {
@@ -170,6 +384,7 @@ func (chain *BlockChain) FindUnspentTransaction(publicKeyHash []byte) []Transact
 					if in.UsesKey(publicKeyHash) {
 						inTransactionID := hex.EncodeToString(in.ID)
 						spentTransaction[inTransactionID] = append(spentTransaction[inTransactionID], in.Out)
+						spent[outpoint(in.ID, in.Out)] = true
 					}
 				}
 			}
@@ -179,17 +394,76 @@ func (chain *BlockChain) FindUnspentTransaction(publicKeyHash []byte) []Transact
 			break
 		}
 	}
-	fmt.Printf("spent transactions %x\n", unspentTransactions)
-	return unspentTransactions
+	return unspentTransactions, spent
+}
+
+func (chain *BlockChain) UsedPublicKeyHashes() map[string]bool {
+	used := make(map[string]bool)
+	iterator := chain.Iterator()
+
+	for {
+		bloco := iterator.Next()
+
+		for _, tx := range bloco.Transactions {
+			for _, output := range tx.Outputs {
+				used[hex.EncodeToString(output.PubKeyHash)] = true
+			}
+		}
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+	return used
+}
+
+func (chain *BlockChain) FindHistory(publicKeyHash []byte) []HistoryEntry {
+	var blocks []*Block
+	var history []HistoryEntry
+	transactions := make(map[string]*Transaction)
+	iterator := chain.Iterator()
+
+	for {
+		bloco := iterator.Next()
+		blocks = append(blocks, bloco)
+		for _, tx := range bloco.Transactions {
+			transactions[hex.EncodeToString(tx.ID)] = tx
+		}
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+
+	for _, bloco := range blocks {
+		for _, tx := range bloco.Transactions {
+			entry := HistoryEntry{ID: tx.ID, Height: bloco.Height, Timestamp: bloco.Timestamp}
+			for _, output := range tx.Outputs {
+				if output.IsLocked(publicKeyHash) {
+					entry.Received += output.Value
+				}
+			}
+			if tx.IsCoinbase() == false {
+				for _, in := range tx.Inputs {
+					previous, ok := transactions[hex.EncodeToString(in.ID)]
+					if ok && in.UsesKey(publicKeyHash) {
+						entry.Sent += previous.Outputs[in.Out].Value
+					}
+				}
+			}
+			if entry.Received != 0 || entry.Sent != 0 {
+				history = append(history, entry)
+			}
+		}
+	}
+	return history
 }
 
 func (chain *BlockChain) FindUTXO(publicKeyHash []byte) []TxOutput {
 	var UTXOs []TxOutput
-	unspentTransactions := chain.FindUnspentTransaction(publicKeyHash)
+	unspentTransactions, spent := chain.findUnspent(publicKeyHash)
 
 	for _, tx := range unspentTransactions {
-		for _, output := range tx.Outputs {
-			if output.IsLocked(publicKeyHash) {
+		for outputID, output := range tx.Outputs {
+			if output.IsLocked(publicKeyHash) && !spent[outpoint(tx.ID, outputID)] {
 				UTXOs = append(UTXOs, output)
 			}
 		}
@@ -198,18 +472,43 @@ func (chain *BlockChain) FindUTXO(publicKeyHash []byte) []TxOutput {
 	return UTXOs
 }
 
-func (chain *BlockChain) FindSpendableOutputs(publicKeyHash []byte, amount int) (int, map[string][]int) {
+// FindSpendableOutputs selects plain coin outputs, leaving those that carry
+// an asset alone.
+func (chain *BlockChain) FindSpendableOutputs(publicKeyHash []byte, amount Amount) (Amount, map[string][]int) {
+	return chain.FindSpendableAssetOutputs(publicKeyHash, nil, amount)
+}
+
+// FindSpendableAssetOutputs selects outputs carrying asset until their asset
+// amounts reach amount. A nil asset selects plain coins by value.
+func (chain *BlockChain) FindSpendableAssetOutputs(publicKeyHash, asset []byte, amount Amount) (Amount, map[string][]int) {
+	holds := func(output TxOutput) (Amount, bool) {
+		if !bytes.Equal(output.Asset, asset) {
+			return 0, false
+		}
+		if asset == nil {
+			return output.Value, true
+		}
+		return output.AssetAmount, true
+	}
 	unspentOuts := make(map[string][]int)
-	fmt.Printf("PublicKey da transfericia de dinheiro: %x\n", publicKeyHash)
-	unspentTransactions := chain.FindUnspentTransaction(publicKeyHash)
-	saldo := 0
+	unspentTransactions, spent := chain.findUnspent(publicKeyHash)
+	immature := chain.immatureCoinbases()
+	pending := chain.mempoolSpends()
+	var saldo Amount
 Work:
 	for _, tx := range unspentTransactions {
 		id := hex.EncodeToString(tx.ID)
+		if immature[id] {
+			continue
+		}
 
 		for outputID, output := range tx.Outputs {
+			value, ok := holds(output)
+			if !ok || spent[outpoint(tx.ID, outputID)] || pending[outpoint(tx.ID, outputID)] != nil {
+				continue
+			}
 			if output.IsLocked(publicKeyHash) && saldo < amount {
-				saldo += output.Value
+				saldo += value
 				unspentOuts[id] = append(unspentOuts[id], outputID)
 				if saldo >= amount {
 					break Work
@@ -217,6 +516,22 @@ Work:
 			}
 		}
 	}
+
+	// Unconfirmed outputs come last, spending them makes the new
+	// transaction a child that can pay for its parents.
+	for _, entry := range chain.Mempool() {
+		id := hex.EncodeToString(entry.Tx.ID)
+		for outputID, output := range entry.Tx.Outputs {
+			if saldo >= amount {
+				return saldo, unspentOuts
+			}
+			value, ok := holds(output)
+			if ok && pending[outpoint(entry.Tx.ID, outputID)] == nil && bytes.Equal(output.PubKeyHash, publicKeyHash) {
+				saldo += value
+				unspentOuts[id] = append(unspentOuts[id], outputID)
+			}
+		}
+	}
 	return saldo, unspentOuts
 }
 
@@ -238,22 +553,78 @@ func (chain *BlockChain) FindTransaction(ID []byte) (Transaction, error) {
 	return Transaction{}, fmt.Errorf("Transaction not found")
 }
 
-func (chain *BlockChain) SignTransaction(t *Transaction, privateKey ecdsa.PrivateKey) {
+// previousTransactions finds the transactions t spends in the chain or, for
+// unconfirmed parents, in the mempool.
+func (chain *BlockChain) previousTransactions(t *Transaction) map[string]Transaction {
+	pending := chain.mempoolTransactions()
 	previousTransaction := make(map[string]Transaction)
 	for _, input := range t.Inputs {
 		tx, err := chain.FindTransaction(input.ID)
+		if parent, ok := pending[hex.EncodeToString(input.ID)]; err != nil && ok {
+			tx, err = *parent, nil
+		}
 		Handle(err)
 		previousTransaction[hex.EncodeToString(tx.ID)] = tx
 	}
-	t.Sign(privateKey, previousTransaction)
+	return previousTransaction
+}
+
+func (chain *BlockChain) SignTransaction(t *Transaction, w wallet.Wallet, hashType SigHashType) int {
+	return t.Sign(w, hashType, chain.previousTransactions(t))
+}
+
+func (chain *BlockChain) InputValue(t *Transaction) Amount {
+	return t.InputValue(chain.previousTransactions(t))
+}
+
+func (chain *BlockChain) SigHashes(t *Transaction) [][]byte {
+	return t.SigHashes(chain.previousTransactions(t))
+}
+
+func (chain *BlockChain) SpentOutputs(t *Transaction) ([]TxOutput, error) {
+	return t.spentOutputs(chain.previousTransactions(t))
 }
 
 func (chain *BlockChain) VerifyTransaction(t *Transaction) bool {
+	err := chain.verifyTransaction(t, nil, chain.mempoolTransactions())
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return true
+}
+
+// verifyTransaction checks t against the chain. Inputs may also spend the
+// unconfirmed transactions in pending: earlier transactions of the same
+// block, or the mempool.
+func (chain *BlockChain) verifyTransaction(t *Transaction, batch *wallet.SchnorrBatch, pending map[string]*Transaction) error {
+	if t.IsCoinbase() {
+		return nil
+	}
+
 	previousTransaction := make(map[string]Transaction)
+	immature := chain.immatureCoinbases()
 	for _, input := range t.Inputs {
 		tx, err := chain.FindTransaction(input.ID)
-		Handle(err)
+		if parent, ok := pending[hex.EncodeToString(input.ID)]; err != nil && ok {
+			if parent.IsCoinbase() {
+				return fmt.Errorf("coinbase %x has not matured yet", parent.ID)
+			}
+			tx, err = *parent, nil
+		}
+		if err != nil {
+			return fmt.Errorf("transaction %x spent by %x not found", input.ID, t.ID)
+		}
+		if immature[hex.EncodeToString(tx.ID)] {
+			return fmt.Errorf("coinbase %x has not matured yet", tx.ID)
+		}
 		previousTransaction[hex.EncodeToString(tx.ID)] = tx
 	}
-	return t.Verify(previousTransaction)
+
+	strict := chain.GetBestHeight()+1 >= chain.Params.StrictSigHeight
+	err := t.Verify(previousTransaction, strict, batch)
+	if err != nil {
+		return err
+	}
+	return chain.checkMint(t, previousTransaction, pending)
 }
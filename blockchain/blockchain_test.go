@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-blockchain/wallet"
+)
+
+func TestGenesisCoinbaseMatures(t *testing.T) {
+	chain, _, address := newTestChain(t, 3)
+	genesis := hex.EncodeToString(chain.GetBlock(chain.LastHash).Transactions[0].ID)
+
+	for height := 1; height <= 3; height++ {
+		immature := chain.immatureCoinbases()[genesis]
+		if want := height < 3; immature != want {
+			t.Fatalf("next height %d: genesis coinbase immature = %t, want %t", height, immature, want)
+		}
+		chain.Generate(address, 1)
+	}
+}
+
+func TestGenerateMinesAtTheNextHeights(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	blocks := chain.Generate(address, 3)
+	for i, block := range blocks {
+		if block.Height != i+1 {
+			t.Errorf("block %d has height %d", i, block.Height)
+		}
+		if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinbase() {
+			t.Errorf("block %d does not hold just its coinbase", i)
+		}
+	}
+	if height := chain.GetBestHeight(); height != 3 {
+		t.Fatalf("best height is %d after generating 3 blocks", height)
+	}
+}
+
+func TestProofOfWorkCommitsToHeader(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	block := chain.Generate(address, 1)[0]
+	if !NewProof(block, chain.Params.Difficulty).Validate() {
+		t.Fatal("a generated block does not validate")
+	}
+
+	// At regtest difficulty a changed preimage still passes now and then,
+	// so compare the data the proof commits to instead.
+	pow := NewProof(block, chain.Params.Difficulty)
+	original := string(pow.InitData(block.Nonce))
+	block.Height++
+	if string(pow.InitData(block.Nonce)) == original {
+		t.Error("changing the height left the proof of work preimage as it was")
+	}
+	block.Height--
+	block.Timestamp++
+	if string(pow.InitData(block.Nonce)) == original {
+		t.Error("changing the timestamp left the proof of work preimage as it was")
+	}
+}
+
+func TestLegacyChainStillValidates(t *testing.T) {
+	chain := openLegacyChain(t)
+	iterator := chain.Iterator()
+	for {
+		block := iterator.Next()
+		if !NewProof(block, chain.Params.Difficulty).Validate() {
+			t.Fatalf("legacy block %x no longer passes its proof of work", block.Hash)
+		}
+		if len(block.PrevHash) == 0 {
+			break
+		}
+	}
+	if median := chain.MedianTimePast(chain.LastHash); median != 0 {
+		t.Fatalf("legacy blocks have a median time past of %d", median)
+	}
+	if immature := chain.immatureCoinbases(); len(immature) != 0 {
+		t.Fatalf("legacy coinbases are immature: %v", immature)
+	}
+}
+
+func TestMedianTimePast(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	chain.Generate(address, medianTimeBlocks+3)
+
+	var timestamps []int64
+	iterator := chain.Iterator()
+	for len(timestamps) < medianTimeBlocks {
+		block := iterator.Next()
+		timestamps = append(timestamps, block.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	if median := chain.MedianTimePast(chain.LastHash); median != timestamps[medianTimeBlocks/2] {
+		t.Fatalf("median time past is %d, want %d", median, timestamps[medianTimeBlocks/2])
+	}
+}
+
+func TestValidateTimestamp(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	chain.Generate(address, 5)
+	median := chain.MedianTimePast(chain.LastHash)
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		valid     bool
+	}{
+		{"at the median time past", median, false},
+		{"before the median time past", median - 60, false},
+		{"just after the median time past", median + 1, true},
+		{"within the future limit", now.Add(maxFutureBlockTime - time.Minute).Unix(), true},
+		{"past the future limit", now.Add(maxFutureBlockTime + time.Minute).Unix(), false},
+	}
+	for _, test := range tests {
+		block := &Block{Timestamp: test.timestamp, PrevHash: chain.LastHash, Height: chain.GetBestHeight() + 1}
+		err := chain.ValidateTimestamp(block)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%s: ValidateTimestamp() = %v", test.name, err)
+		}
+	}
+}
+
+func TestFindHistory(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	other := wallet.MakeWallet(wallet.P256)
+	to := string(other.Address(chain.Params.AddressVersion))
+
+	tx := NewTransaction(address, w.PublicKey, to, Coins(30), 0, false, func() string { return address }, chain)
+	signTransaction(t, chain, tx, w)
+	if err := chain.AddBlock([]*Transaction{tx}); err != nil {
+		t.Fatal(err)
+	}
+
+	var received, sent Amount
+	for _, entry := range chain.FindHistory(wallet.PublicKeyHash(w.PublicKey)) {
+		received += entry.Received
+		sent += entry.Sent
+	}
+	if received != Coins(270) || sent != Coins(100) {
+		t.Fatalf("the sender received %s and sent %s, want 270 and 100", received, sent)
+	}
+
+	history := chain.FindHistory(wallet.PublicKeyHash(other.PublicKey))
+	if len(history) != 1 || history[0].Received != Coins(30) || history[0].Sent != 0 || history[0].Height != 2 {
+		t.Fatalf("the recipient history is %+v", history)
+	}
+	if !bytes.Equal(history[0].ID, tx.ID) {
+		t.Fatalf("the recipient history names %x, want %x", history[0].ID, tx.ID)
+	}
+}
+
+func TestAddBlockRejectsInvalidTransaction(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	coinbase := coinbaseTx(t, chain, address, "next block")
+
+	tx := NewTransaction(address, w.PublicKey, address, 1, 0, false, nil, chain)
+	signTransaction(t, chain, tx, w)
+	tx.Outputs[0].Value++
+
+	lastHash := chain.LastHash
+	err := chain.AddBlock([]*Transaction{coinbase, tx})
+	if err == nil {
+		t.Fatal("a block with a tampered transaction was added")
+	}
+	if !strings.Contains(err.Error(), hex.EncodeToString(tx.ID)) {
+		t.Errorf("error %q does not name transaction %x", err, tx.ID)
+	}
+	if !bytes.Equal(chain.LastHash, lastHash) {
+		t.Fatal("the chain tip moved after a rejected block")
+	}
+}
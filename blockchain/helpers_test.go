@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang-blockchain/params"
+	"golang-blockchain/wallet"
+)
+
+// newTestChain creates a regtest chain in a temporary directory whose
+// genesis reward goes to a new key.
+func newTestChain(t *testing.T, maturity int) (*BlockChain, *wallet.Wallet, string) {
+	t.Helper()
+	p := params.RegTest
+	p.SetDataDir(t.TempDir())
+	p.CoinbaseMaturity = maturity
+	w := wallet.MakeWallet(wallet.P256)
+	address := string(w.Address(p.AddressVersion))
+	chain := InitBlockChain(address, &p)
+	t.Cleanup(func() { chain.Database.Close() })
+	return chain, w, address
+}
+
+// openLegacyChain opens a copy of the chain in tmp/, which was mined before
+// blocks had heights or timestamps.
+func openLegacyChain(t *testing.T) *BlockChain {
+	t.Helper()
+	source := filepath.Join("..", "tmp", "blocks")
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		t.Skip(err)
+	}
+	p := params.MainNet
+	p.SetDataDir(t.TempDir())
+	if err := os.MkdirAll(p.DBPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(source, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p.DBPath(), entry.Name()), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain := ContinueBlockChain("", &p)
+	t.Cleanup(func() { chain.Database.Close() })
+	return chain
+}
+
+func signTransaction(t *testing.T, chain *BlockChain, tx *Transaction, w *wallet.Wallet) {
+	t.Helper()
+	chain.SignTransaction(tx, *w, SigHashAll)
+	if !chain.VerifyTransaction(tx) {
+		t.Fatal("the transaction does not verify")
+	}
+}
+
+func coinbaseTx(t *testing.T, chain *BlockChain, to, data string) *Transaction {
+	t.Helper()
+	tx, err := CoinbaseTx(to, data, Coins(chain.Params.Reward), chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import "fmt"
+
+func (tx *Transaction) SigOps() int {
+	if tx.IsCoinbase() {
+		return 0
+	}
+	return len(tx.Inputs)
+}
+
+func (chain *BlockChain) CheckTransactionLimits(tx *Transaction) error {
+	p := chain.Params
+
+	if len(tx.Inputs) == 0 || len(tx.Outputs) == 0 {
+		return fmt.Errorf("transaction %x has no inputs or no outputs", tx.ID)
+	}
+	if len(tx.Inputs) > p.MaxTxInputs {
+		return fmt.Errorf("transaction %x has %d inputs, the limit is %d", tx.ID, len(tx.Inputs), p.MaxTxInputs)
+	}
+	if len(tx.Outputs) > p.MaxTxOutputs {
+		return fmt.Errorf("transaction %x has %d outputs, the limit is %d", tx.ID, len(tx.Outputs), p.MaxTxOutputs)
+	}
+	if size := len(tx.Serialize()); size > p.MaxTxSize {
+		return fmt.Errorf("transaction %x is %d bytes, the limit is %d", tx.ID, size, p.MaxTxSize)
+	}
+
+	var total Amount
+	for i, output := range tx.Outputs {
+		if output.Value < 0 {
+			return fmt.Errorf("output %d of %x has a negative value %s", i, tx.ID, output.Value)
+		}
+		if output.Value > MaxMoney {
+			return fmt.Errorf("output %d of %x pays %s, more than the %s coins that can exist", i, tx.ID, output.Value, MaxMoney)
+		}
+		// Older versions store and sign whole coins, a fraction would be
+		// dropped from both and could be changed without breaking either.
+		if tx.Version < amountVersion && output.Value%Coin != 0 {
+			return fmt.Errorf("output %d of %x pays %s, version %d transactions can only pay whole coins", i, tx.ID, output.Value, tx.Version)
+		}
+		total += output.Value
+		if total > MaxMoney {
+			return fmt.Errorf("the outputs of %x pay more than the %s coins that can exist", tx.ID, MaxMoney)
+		}
+	}
+	return tx.checkAssets()
+}
+
+func (chain *BlockChain) ValidateBlockLimits(block *Block) error {
+	p := chain.Params
+
+	if len(block.Transactions) == 0 {
+		return fmt.Errorf("block has no transactions")
+	}
+
+	sigOps := 0
+	for _, tx := range block.Transactions {
+		err := chain.CheckTransactionLimits(tx)
+		if err != nil {
+			return err
+		}
+		sigOps += tx.SigOps()
+	}
+	if sigOps > p.MaxBlockSigOps {
+		return fmt.Errorf("block has %d signature operations, the limit is %d", sigOps, p.MaxBlockSigOps)
+	}
+	if size := len(block.Serialize()); size > p.MaxBlockSize {
+		return fmt.Errorf("block is %d bytes, the limit is %d", size, p.MaxBlockSize)
+	}
+	return nil
+}
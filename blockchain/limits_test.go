@@ -0,0 +1,144 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func spendingTx(t *testing.T, chain *BlockChain, inputs, outputs int, address string) *Transaction {
+	t.Helper()
+	tx := &Transaction{Version: TxVersion}
+	for i := 0; i < inputs; i++ {
+		tx.Inputs = append(tx.Inputs, TxInput{ID: []byte(fmt.Sprintf("previous %d", i)), Out: i, Signature: make([]byte, 64), PubKey: make([]byte, 64)})
+	}
+	for i := 0; i < outputs; i++ {
+		output, err := NewTxOutput(1, address, chain.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.Outputs = append(tx.Outputs, *output)
+	}
+	tx.SetID()
+	return tx
+}
+
+func withValues(tx *Transaction, values ...Amount) *Transaction {
+	for i, value := range values {
+		tx.Outputs[i].Value = value
+	}
+	tx.SetID()
+	return tx
+}
+
+func TestCheckTransactionLimits(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	chain.Params.MaxTxInputs = 3
+	chain.Params.MaxTxOutputs = 2
+	chain.Params.MaxTxSize = 2000
+
+	tests := []struct {
+		name  string
+		tx    *Transaction
+		valid bool
+	}{
+		{"within the limits", spendingTx(t, chain, 3, 2, address), true},
+		{"too many inputs", spendingTx(t, chain, 4, 1, address), false},
+		{"too many outputs", spendingTx(t, chain, 1, 3, address), false},
+		{"no inputs", spendingTx(t, chain, 0, 1, address), false},
+		{"no outputs", spendingTx(t, chain, 1, 0, address), false},
+		{"a coinbase", coinbaseTx(t, chain, address, "limits"), true},
+		{"a zero output", withValues(spendingTx(t, chain, 1, 1, address), 0), true},
+		{"a negative output", withValues(spendingTx(t, chain, 1, 2, address), 5, -1), false},
+		{"outputs overflowing", withValues(spendingTx(t, chain, 1, 2, address), math.MaxInt, 1), false},
+	}
+	for _, test := range tests {
+		err := chain.CheckTransactionLimits(test.tx)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%s: CheckTransactionLimits() = %v", test.name, err)
+		}
+	}
+
+	chain.Params.MaxTxSize = len(spendingTx(t, chain, 3, 2, address).Serialize()) - 1
+	if err := chain.CheckTransactionLimits(spendingTx(t, chain, 3, 2, address)); err == nil {
+		t.Error("an oversized transaction passed the limits")
+	}
+}
+
+func TestValidateBlockLimits(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	chain.Params.MaxBlockSigOps = 4
+	block := &Block{PrevHash: chain.LastHash, Height: 1, Transactions: []*Transaction{
+		coinbaseTx(t, chain, address, "sigops"),
+		spendingTx(t, chain, 2, 1, address),
+		spendingTx(t, chain, 2, 1, address),
+	}}
+
+	if err := chain.ValidateBlockLimits(block); err != nil {
+		t.Fatalf("a block with %d sigops failed: %v", chain.Params.MaxBlockSigOps, err)
+	}
+	block.Transactions = append(block.Transactions, spendingTx(t, chain, 1, 1, address))
+	if err := chain.ValidateBlockLimits(block); err == nil {
+		t.Error("a block over the sigop limit passed")
+	}
+
+	chain.Params.MaxBlockSigOps = 100
+	chain.Params.MaxBlockSize = len(block.Serialize()) - 1
+	if err := chain.ValidateBlockLimits(block); err == nil {
+		t.Error("an oversized block passed")
+	}
+
+	if err := chain.ValidateBlockLimits(&Block{PrevHash: chain.LastHash, Height: 1}); err == nil {
+		t.Error("an empty block passed")
+	}
+}
+
+func TestAddBlockEnforcesLimits(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	chain.Params.MaxBlockSize = 100
+	tip := chain.LastHash
+
+	err := chain.AddBlock([]*Transaction{coinbaseTx(t, chain, address, "too big")})
+	if err == nil {
+		t.Fatal("AddBlock accepted a block over the size limit")
+	}
+	if !bytes.Equal(chain.LastHash, tip) || chain.GetBestHeight() != 0 {
+		t.Fatal("a rejected block moved the tip")
+	}
+}
+
+func TestLegacyTransactionsPayWholeCoins(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+
+	output, err := NewTxOutput(Coins(99), address, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &Transaction{
+		Version: amountVersion - 1,
+		Inputs:  []TxInput{{ID: coin.tx.ID, Out: coin.out, PubKey: w.PublicKey}},
+		Outputs: []TxOutput{*output},
+	}
+	tx.ID = tx.Hash()
+	chain.SignTransaction(tx, *w, SigHashAll)
+	if err := chain.CheckTransactionLimits(tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fraction of a coin is neither stored nor signed, so it would leave
+	// the ID and the signature as they are.
+	fractional := *tx
+	fractional.Outputs = []TxOutput{tx.Outputs[0]}
+	fractional.Outputs[0].Value++
+	if !bytes.Equal(fractional.Hash(), tx.Hash()) || !bytes.Equal(chain.SigHashes(&fractional)[0], chain.SigHashes(tx)[0]) {
+		t.Fatal("the fraction changed the hash or sighash of a legacy transaction")
+	}
+	if err := chain.CheckTransactionLimits(&fractional); err == nil {
+		t.Fatal("a legacy transaction paying a fraction of a coin passed the limits")
+	}
+	expectReject(t, chain, &fractional, "invalid")
+
+	accept(t, chain, tx)
+}
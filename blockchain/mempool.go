@@ -0,0 +1,481 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	mempoolPrefix = "mempool-"
+
+	// maxReplacedTransactions bounds how much of the mempool a single
+	// replacement can evict.
+	maxReplacedTransactions = 100
+
+	// maxMempoolAncestors bounds the chains of unconfirmed transactions
+	// that package accounting has to walk.
+	maxMempoolAncestors = 25
+
+	// blockTemplateReserve leaves room in a block for the coinbase and the
+	// block header.
+	blockTemplateReserve = 1000
+)
+
+// A MempoolEntry is a transaction waiting for a block, with the fee it pays
+// over the outputs it spends.
+type MempoolEntry struct {
+	Tx   *Transaction
+	Fee  Amount
+	Size int
+	Time int64
+}
+
+// FeeRate is the fee per 1000 bytes.
+func (e *MempoolEntry) FeeRate() Amount {
+	return e.Fee * 1000 / Amount(e.Size)
+}
+
+// paysMoreThan compares fee rates without rounding.
+func (e *MempoolEntry) paysMoreThan(other *MempoolEntry) bool {
+	return feeRateAbove(e.Fee, e.Size, other.Fee, other.Size)
+}
+
+// A MempoolPackage is a pending transaction with its unconfirmed ancestors,
+// which a miner has to include before it. Fee and Size cover the whole
+// package, so a child paying a high fee lifts its parents.
+type MempoolPackage struct {
+	Entry       *MempoolEntry
+	Ancestors   []*MempoolEntry
+	Descendants []*MempoolEntry
+	Fee         Amount
+	Size        int
+}
+
+func (p *MempoolPackage) FeeRate() Amount {
+	return p.Fee * 1000 / Amount(p.Size)
+}
+
+// mempoolGraph links pending transactions through the outputs they spend
+// from each other.
+type mempoolGraph struct {
+	entries     []*MempoolEntry
+	ancestors   map[*MempoolEntry][]*MempoolEntry
+	descendants map[*MempoolEntry][]*MempoolEntry
+}
+
+func newMempoolGraph(entries []*MempoolEntry) *mempoolGraph {
+	byID := make(map[string]*MempoolEntry)
+	for _, entry := range entries {
+		byID[hex.EncodeToString(entry.Tx.ID)] = entry
+	}
+	parents := make(map[*MempoolEntry][]*MempoolEntry)
+	for _, entry := range entries {
+		for _, input := range entry.Tx.Inputs {
+			parent := byID[hex.EncodeToString(input.ID)]
+			if parent != nil && !containsEntry(parents[entry], parent) {
+				parents[entry] = append(parents[entry], parent)
+			}
+		}
+	}
+
+	graph := &mempoolGraph{
+		entries:     entries,
+		ancestors:   make(map[*MempoolEntry][]*MempoolEntry),
+		descendants: make(map[*MempoolEntry][]*MempoolEntry),
+	}
+	for _, entry := range entries {
+		stack := append([]*MempoolEntry{}, parents[entry]...)
+		for len(stack) > 0 {
+			ancestor := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if containsEntry(graph.ancestors[entry], ancestor) {
+				continue
+			}
+			graph.ancestors[entry] = append(graph.ancestors[entry], ancestor)
+			graph.descendants[ancestor] = append(graph.descendants[ancestor], entry)
+			stack = append(stack, parents[ancestor]...)
+		}
+	}
+	return graph
+}
+
+func (g *mempoolGraph) newPackage(entry *MempoolEntry) *MempoolPackage {
+	p := &MempoolPackage{
+		Entry:       entry,
+		Ancestors:   g.ancestors[entry],
+		Descendants: g.descendants[entry],
+		Fee:         entry.Fee,
+		Size:        entry.Size,
+	}
+	for _, ancestor := range p.Ancestors {
+		p.Fee += ancestor.Fee
+		p.Size += ancestor.Size
+	}
+	return p
+}
+
+// signalsReplaceable follows BIP 125 in letting a transaction inherit
+// replaceability from its unconfirmed ancestors.
+func (g *mempoolGraph) signalsReplaceable(entry *MempoolEntry) bool {
+	if entry.Tx.Replaceable() {
+		return true
+	}
+	for _, ancestor := range g.ancestors[entry] {
+		if ancestor.Tx.Replaceable() {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *MempoolEntry) Serialize() []byte {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(e)
+	Handle(err)
+	return buffer.Bytes()
+}
+
+func DeserializeMempoolEntry(data []byte) *MempoolEntry {
+	var entry MempoolEntry
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry)
+	Handle(err)
+	return &entry
+}
+
+func mempoolKey(id []byte) []byte {
+	return append([]byte(mempoolPrefix), id...)
+}
+
+func outpoint(id []byte, out int) string {
+	return fmt.Sprintf("%x:%d", id, out)
+}
+
+// Mempool returns the pending transactions, oldest first.
+func (chain *BlockChain) Mempool() []*MempoolEntry {
+	var entries []*MempoolEntry
+	err := chain.Database.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte(mempoolPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, DeserializeMempoolEntry(data))
+		}
+		return nil
+	})
+	Handle(err)
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time < entries[j].Time })
+	return entries
+}
+
+func (chain *BlockChain) MempoolEntry(id []byte) (*MempoolEntry, error) {
+	for _, entry := range chain.Mempool() {
+		if bytes.Equal(entry.Tx.ID, id) {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %x is not in the mempool", id)
+}
+
+// MempoolPackages returns every pending transaction with its package,
+// best paying package first. This is the order blocks are filled in.
+func (chain *BlockChain) MempoolPackages() []*MempoolPackage {
+	graph := newMempoolGraph(chain.Mempool())
+	var packages []*MempoolPackage
+	for _, entry := range graph.entries {
+		packages = append(packages, graph.newPackage(entry))
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		return feeRateAbove(packages[i].Fee, packages[i].Size, packages[j].Fee, packages[j].Size)
+	})
+	return packages
+}
+
+func (chain *BlockChain) mempoolTransactions() map[string]*Transaction {
+	transactions := make(map[string]*Transaction)
+	for _, entry := range chain.Mempool() {
+		transactions[hex.EncodeToString(entry.Tx.ID)] = entry.Tx
+	}
+	return transactions
+}
+
+// mempoolSpends maps every outpoint spent by a pending transaction to it.
+func (chain *BlockChain) mempoolSpends() map[string]*MempoolEntry {
+	spends := make(map[string]*MempoolEntry)
+	for _, entry := range chain.Mempool() {
+		for _, input := range entry.Tx.Inputs {
+			spends[outpoint(input.ID, input.Out)] = entry
+		}
+	}
+	return spends
+}
+
+// spentOutpoints collects every outpoint spent in the chain.
+func (chain *BlockChain) spentOutpoints() map[string]bool {
+	spent := make(map[string]bool)
+	iterator := chain.Iterator()
+
+	for {
+		bloco := iterator.Next()
+		for _, tx := range bloco.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, input := range tx.Inputs {
+				spent[outpoint(input.ID, input.Out)] = true
+			}
+		}
+		if len(bloco.PrevHash) == 0 {
+			break
+		}
+	}
+	return spent
+}
+
+// AcceptToMempool checks a transaction against the consensus rules, the
+// pending transactions and the relay policy and queues it for the next
+// block, evicting the pending transactions it replaces. Those are returned.
+// Every rejection is a *RejectError.
+func (chain *BlockChain) AcceptToMempool(tx *Transaction, policy *Policy) ([]*MempoolEntry, error) {
+	if tx.IsCoinbase() {
+		return nil, reject("coinbase", "coinbase %x is only valid in a block", tx.ID)
+	}
+	err := chain.CheckTransactionLimits(tx)
+	if err != nil {
+		return nil, reject("invalid", "%s", err)
+	}
+	entries := chain.Mempool()
+	graph := newMempoolGraph(entries)
+	pending := make(map[string]*Transaction)
+	spends := make(map[string]*MempoolEntry)
+	for _, entry := range entries {
+		if bytes.Equal(entry.Tx.ID, tx.ID) {
+			return nil, reject("already-known", "transaction %x is already in the mempool", tx.ID)
+		}
+		pending[hex.EncodeToString(entry.Tx.ID)] = entry.Tx
+		for _, input := range entry.Tx.Inputs {
+			spends[outpoint(input.ID, input.Out)] = entry
+		}
+	}
+
+	spent := chain.spentOutpoints()
+	seen := make(map[string]bool)
+	var conflicts []*MempoolEntry
+	for i, input := range tx.Inputs {
+		point := outpoint(input.ID, input.Out)
+		if seen[point] {
+			return nil, reject("invalid", "input %d of %x spends %s twice", i, tx.ID, point)
+		}
+		seen[point] = true
+		if spent[point] {
+			return nil, reject("missing-or-spent", "input %d of %x spends %s, which is already spent in the chain", i, tx.ID, point)
+		}
+		if conflict := spends[point]; conflict != nil && !containsEntry(conflicts, conflict) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	err = chain.verifyTransaction(tx, nil, pending)
+	if err != nil {
+		return nil, reject("invalid", "%s", err)
+	}
+	entry := &MempoolEntry{
+		Tx:   tx,
+		Fee:  chain.InputValue(tx) - tx.OutputValue(),
+		Size: len(tx.Serialize()),
+		Time: time.Now().Unix(),
+	}
+	err = policy.CheckStandard(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replacing a transaction also evicts everything spending its outputs.
+	evicted := append([]*MempoolEntry{}, conflicts...)
+	for _, conflict := range conflicts {
+		for _, descendant := range graph.descendants[conflict] {
+			if !containsEntry(evicted, descendant) {
+				evicted = append(evicted, descendant)
+			}
+		}
+	}
+	err = checkReplacement(entry, conflicts, evicted, graph, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := newMempoolGraph(append(entries, entry)).ancestors[entry]
+	if len(ancestors) > maxMempoolAncestors {
+		return nil, reject("too-many-ancestors", "transaction %x has %d unconfirmed ancestors, the limit is %d", tx.ID, len(ancestors), maxMempoolAncestors)
+	}
+
+	err = chain.Database.Update(func(txn *badger.Txn) error {
+		for _, replaced := range evicted {
+			if err := txn.Delete(mempoolKey(replaced.Tx.ID)); err != nil {
+				return err
+			}
+		}
+		return txn.Set(mempoolKey(tx.ID), entry.Serialize())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return evicted, nil
+}
+
+func containsEntry(entries []*MempoolEntry, entry *MempoolEntry) bool {
+	for _, e := range entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReplacement applies the replace-by-fee rules. Every transaction in
+// conflict must signal replaceability, itself or through an unconfirmed
+// ancestor. The replacement must pay a strictly higher fee rate than each of
+// them and a strictly higher fee than everything it evicts together, their
+// descendants included, and it may not evict more than
+// maxReplacedTransactions or spend from what it evicts. A miner therefore
+// never earns less from the replacement than from what it evicts, and the
+// extra fee has to pay for relaying the replacement at the minimum rate.
+func checkReplacement(entry *MempoolEntry, conflicts, evicted []*MempoolEntry, graph *mempoolGraph, policy *Policy) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if len(evicted) > maxReplacedTransactions {
+		return reject("too-many-replacements", "transaction %x would replace %d transactions, the limit is %d", entry.Tx.ID, len(evicted), maxReplacedTransactions)
+	}
+
+	for _, conflict := range conflicts {
+		if !graph.signalsReplaceable(conflict) {
+			return reject("not-replaceable", "transaction %x conflicts with %x, which did not signal replaceability", entry.Tx.ID, conflict.Tx.ID)
+		}
+		if !entry.paysMoreThan(conflict) {
+			return reject("insufficient-fee", "transaction %x pays a fee rate of %s, it must beat the %s of %x it replaces", entry.Tx.ID, entry.FeeRate(), conflict.FeeRate(), conflict.Tx.ID)
+		}
+	}
+
+	var replacedFees Amount
+	for _, replaced := range evicted {
+		for _, input := range entry.Tx.Inputs {
+			if bytes.Equal(input.ID, replaced.Tx.ID) {
+				return reject("replaces-parent", "transaction %x spends %x, which it would replace", entry.Tx.ID, replaced.Tx.ID)
+			}
+		}
+		replacedFees += replaced.Fee
+	}
+	if entry.Fee <= replacedFees {
+		return reject("insufficient-fee", "transaction %x pays a fee of %s, it must beat the %s of the transactions it replaces", entry.Tx.ID, entry.Fee, replacedFees)
+	}
+	if extra, minimum := entry.Fee-replacedFees, policy.RelayFee(entry.Size); extra < minimum {
+		return reject("insufficient-fee", "transaction %x adds a fee of %s, relaying its %d bytes needs %s", entry.Tx.ID, extra, entry.Size, minimum)
+	}
+	return nil
+}
+
+// removeConfirmed drops the pending transactions a new block confirmed, and
+// those it made invalid by spending the same outputs together with their
+// descendants.
+func (chain *BlockChain) removeConfirmed(block *Block) error {
+	confirmed := make(map[string]bool)
+	spent := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		confirmed[hex.EncodeToString(tx.ID)] = true
+		for _, input := range tx.Inputs {
+			spent[outpoint(input.ID, input.Out)] = true
+		}
+	}
+
+	graph := newMempoolGraph(chain.Mempool())
+	var removed []*MempoolEntry
+	for _, entry := range graph.entries {
+		if confirmed[hex.EncodeToString(entry.Tx.ID)] {
+			removed = append(removed, entry)
+			continue
+		}
+		for _, input := range entry.Tx.Inputs {
+			if spent[outpoint(input.ID, input.Out)] {
+				removed = append(removed, entry)
+				removed = append(removed, graph.descendants[entry]...)
+				break
+			}
+		}
+	}
+
+	return chain.Database.Update(func(txn *badger.Txn) error {
+		for _, entry := range removed {
+			if err := txn.Delete(mempoolKey(entry.Tx.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BlockTemplate fills a block the way ancestor package selection does:
+// it repeatedly takes the package with the best combined fee rate that
+// still fits, leaving out ancestors already taken, and returns the
+// transactions with parents before children and the fees they pay.
+func (chain *BlockChain) BlockTemplate() ([]*Transaction, Amount) {
+	graph := newMempoolGraph(chain.Mempool())
+	included := make(map[*MempoolEntry]bool)
+
+	var transactions []*Transaction
+	size, sigOps, fees := blockTemplateReserve, 0, Amount(0)
+	for {
+		var best []*MempoolEntry
+		bestFee, bestSize, bestSigOps := Amount(0), 0, 0
+		for _, entry := range graph.entries {
+			if included[entry] {
+				continue
+			}
+			candidate := []*MempoolEntry{entry}
+			for _, ancestor := range graph.ancestors[entry] {
+				if !included[ancestor] {
+					candidate = append(candidate, ancestor)
+				}
+			}
+			fee, candidateSize, candidateSigOps := Amount(0), 0, 0
+			for _, member := range candidate {
+				fee += member.Fee
+				candidateSize += member.Size
+				candidateSigOps += member.Tx.SigOps()
+			}
+			if size+candidateSize > chain.Params.MaxBlockSize || sigOps+candidateSigOps > chain.Params.MaxBlockSigOps {
+				continue
+			}
+			if best == nil || feeRateAbove(fee, candidateSize, bestFee, bestSize) {
+				best, bestFee, bestSize, bestSigOps = candidate, fee, candidateSize, candidateSigOps
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		// A parent has fewer ancestors than any of its children.
+		sort.SliceStable(best, func(i, j int) bool {
+			return len(graph.ancestors[best[i]]) < len(graph.ancestors[best[j]])
+		})
+		for _, member := range best {
+			included[member] = true
+			transactions = append(transactions, member.Tx)
+		}
+		size += bestSize
+		sigOps += bestSigOps
+		fees += bestFee
+	}
+	return transactions, fees
+}
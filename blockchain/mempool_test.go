@@ -0,0 +1,283 @@
+package blockchain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang-blockchain/wallet"
+)
+
+type testCoin struct {
+	tx  *Transaction
+	out int
+}
+
+// spendCoins signs a transaction paying everything in coins but fee back to
+// address.
+func spendCoins(t *testing.T, chain *BlockChain, w *wallet.Wallet, address string, fee Amount, replaceable bool, coins ...testCoin) *Transaction {
+	t.Helper()
+	var inputs []TxInput
+	var total Amount
+	for _, coin := range coins {
+		inputs = append(inputs, TxInput{ID: coin.tx.ID, Out: coin.out, PubKey: w.PublicKey, Replaceable: replaceable})
+		total += coin.tx.Outputs[coin.out].Value
+	}
+	output, err := NewTxOutput(total-fee, address, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &Transaction{Version: TxVersion, Inputs: inputs, Outputs: []TxOutput{*output}}
+	tx.ID = tx.Hash()
+	if signed := chain.SignTransaction(tx, *w, SigHashAll); signed != len(inputs) {
+		t.Fatalf("signed %d of %d inputs", signed, len(inputs))
+	}
+	return tx
+}
+
+// matureCoins mines count blocks to address and returns their coinbases.
+func matureCoins(chain *BlockChain, address string, count int) []testCoin {
+	var coins []testCoin
+	for _, block := range chain.Generate(address, count) {
+		coins = append(coins, testCoin{block.Transactions[0], 0})
+	}
+	return coins
+}
+
+// testPolicy relays every valid transaction paying a fee at all, so the fees
+// in these tests can stay small.
+func testPolicy() *Policy {
+	policy := DefaultPolicy()
+	policy.MinRelayFee = 0
+	return policy
+}
+
+func accept(t *testing.T, chain *BlockChain, tx *Transaction) []*MempoolEntry {
+	t.Helper()
+	evicted, err := chain.AcceptToMempool(tx, testPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return evicted
+}
+
+func expectReject(t *testing.T, chain *BlockChain, tx *Transaction, reason string) {
+	t.Helper()
+	_, err := chain.AcceptToMempool(tx, testPolicy())
+	if err == nil || !strings.Contains(err.Error(), reason) {
+		t.Fatalf("got %v, want a rejection mentioning %q", err, reason)
+	}
+}
+
+func inMempool(chain *BlockChain, tx *Transaction) bool {
+	_, err := chain.MempoolEntry(tx.ID)
+	return err == nil
+}
+
+func TestReplacementNeedsSignal(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+
+	original := spendCoins(t, chain, w, address, 1, false, coin)
+	accept(t, chain, original)
+	expectReject(t, chain, spendCoins(t, chain, w, address, 5, true, coin), "did not signal replaceability")
+	if !inMempool(chain, original) {
+		t.Fatal("a rejected replacement evicted the original")
+	}
+}
+
+func TestReplacementNeedsHigherFee(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+
+	original := spendCoins(t, chain, w, address, 2, true, coin)
+	accept(t, chain, original)
+	expectReject(t, chain, spendCoins(t, chain, w, address, 1, true, coin), "it must beat")
+
+	replacement := spendCoins(t, chain, w, address, 5, true, coin)
+	evicted := accept(t, chain, replacement)
+	if len(evicted) != 1 || !bytes.Equal(evicted[0].Tx.ID, original.ID) {
+		t.Fatalf("replacement evicted %d transactions, want only the original", len(evicted))
+	}
+	if inMempool(chain, original) || !inMempool(chain, replacement) {
+		t.Fatal("the mempool does not hold just the replacement")
+	}
+}
+
+func TestMempoolRejectsSpentOutputs(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+
+	confirmed := spendCoins(t, chain, w, address, 1, false, coin)
+	accept(t, chain, confirmed)
+	chain.Generate(address, 1)
+	if inMempool(chain, confirmed) {
+		t.Fatal("a mined transaction stayed in the mempool")
+	}
+	expectReject(t, chain, spendCoins(t, chain, w, address, 5, true, coin), "already spent in the chain")
+	fresh := matureCoins(chain, address, 1)[0]
+	expectReject(t, chain, spendCoins(t, chain, w, address, 1, true, fresh, fresh), "twice")
+}
+
+func TestGenerateCollectsFees(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coins := matureCoins(chain, address, 2)
+	accept(t, chain, spendCoins(t, chain, w, address, 3, false, coins[0]))
+	accept(t, chain, spendCoins(t, chain, w, address, 4, false, coins[1]))
+
+	block := chain.Generate(address, 1)[0]
+	if len(block.Transactions) != 3 {
+		t.Fatalf("the block holds %d transactions, want the coinbase and both payments", len(block.Transactions))
+	}
+	if paid := block.Transactions[0].OutputValue(); paid != Coins(chain.Params.Reward)+7 {
+		t.Fatalf("the coinbase pays %s, want the reward and 7 in fees", paid)
+	}
+	if len(chain.Mempool()) != 0 {
+		t.Fatalf("%d transactions are left in the mempool after mining", len(chain.Mempool()))
+	}
+}
+
+func TestAddBlockChecksCoinbaseAndDoubleSpends(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coins := matureCoins(chain, address, 2)
+	spent := spendCoins(t, chain, w, address, 2, false, coins[0])
+	if err := chain.AddBlock([]*Transaction{spent}); err != nil {
+		t.Fatal(err)
+	}
+
+	coinbase := func(value Amount) *Transaction {
+		tx, err := CoinbaseTx(address, "test", value, chain.Params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	payment := spendCoins(t, chain, w, address, 3, false, coins[1])
+	conflict := spendCoins(t, chain, w, address, 4, false, coins[1])
+	tests := []struct {
+		name         string
+		transactions []*Transaction
+		reason       string
+	}{
+		{"a coinbase paying more than the fees", []*Transaction{coinbase(Coins(chain.Params.Reward) + 4), payment}, "the reward and fees of the block are"},
+		{"a coinbase after a payment", []*Transaction{payment, coinbase(Coins(chain.Params.Reward))}, "is not the first transaction"},
+		{"two coinbases", []*Transaction{coinbase(Coins(chain.Params.Reward)), coinbase(1)}, "is not the first transaction"},
+		{"an output spent in the chain", []*Transaction{spendCoins(t, chain, w, address, 1, false, coins[0])}, "already spent in the chain"},
+		{"an output spent twice in the block", []*Transaction{payment, conflict}, "spent twice in the block"},
+		{"an output spent twice in a transaction", []*Transaction{spendCoins(t, chain, w, address, 1, false, coins[1], coins[1])}, "spent twice in the block"},
+		{"a coinbase paying the fees", []*Transaction{coinbase(Coins(chain.Params.Reward) + 3), payment}, ""},
+	}
+	for _, test := range tests {
+		lastHash := chain.LastHash
+		err := chain.AddBlock(test.transactions)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: got %v, want an error mentioning %q", test.name, err, test.reason)
+		}
+		if !bytes.Equal(chain.LastHash, lastHash) {
+			t.Fatalf("%s: the chain tip moved after a rejected block", test.name)
+		}
+	}
+}
+
+func TestReplacementEvictsDescendants(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+
+	parent := spendCoins(t, chain, w, address, 1, true, coin)
+	accept(t, chain, parent)
+	child := spendCoins(t, chain, w, address, 1, false, testCoin{parent, 0})
+	accept(t, chain, child)
+	grandchild := spendCoins(t, chain, w, address, 1, false, testCoin{child, 0})
+	accept(t, chain, grandchild)
+
+	// Beating the parent's fee rate is not enough, the replacement has to
+	// pay for the whole family it evicts.
+	expectReject(t, chain, spendCoins(t, chain, w, address, 2, true, coin), "it must beat")
+
+	replacement := spendCoins(t, chain, w, address, 10, true, coin)
+	if evicted := accept(t, chain, replacement); len(evicted) != 3 {
+		t.Fatalf("replacement evicted %d transactions, want 3", len(evicted))
+	}
+	for _, tx := range []*Transaction{parent, child, grandchild} {
+		if inMempool(chain, tx) {
+			t.Fatalf("transaction %x survived the replacement of its ancestor", tx.ID)
+		}
+	}
+	if len(chain.Mempool()) != 1 {
+		t.Fatalf("mempool holds %d transactions, want only the replacement", len(chain.Mempool()))
+	}
+}
+
+func TestChildPaysForParent(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coins := matureCoins(chain, address, 2)
+
+	parent := spendCoins(t, chain, w, address, 1, false, coins[0])
+	accept(t, chain, parent)
+	other := spendCoins(t, chain, w, address, 5, false, coins[1])
+	accept(t, chain, other)
+	child := spendCoins(t, chain, w, address, 50, false, testCoin{parent, 0})
+	accept(t, chain, child)
+
+	packages := chain.MempoolPackages()
+	best := packages[0]
+	if !bytes.Equal(best.Entry.Tx.ID, child.ID) || len(best.Ancestors) != 1 || !bytes.Equal(best.Ancestors[0].Tx.ID, parent.ID) {
+		t.Fatalf("best package is %x with %d ancestors, want the child with its parent", best.Entry.Tx.ID, len(best.Ancestors))
+	}
+	if best.Fee != 51 {
+		t.Fatalf("package fee is %s, want the parent's and the child's together", best.Fee)
+	}
+
+	transactions, fees := chain.BlockTemplate()
+	want := []*Transaction{parent, child, other}
+	if len(transactions) != len(want) {
+		t.Fatalf("template holds %d transactions, want %d", len(transactions), len(want))
+	}
+	for i, tx := range want {
+		if !bytes.Equal(transactions[i].ID, tx.ID) {
+			t.Fatalf("template transaction %d is %x, want %x", i, transactions[i].ID, tx.ID)
+		}
+	}
+	if fees != 56 {
+		t.Fatalf("template pays %s in fees, want 56", fees)
+	}
+
+	chain.Generate(address, 1)
+	if len(chain.Mempool()) != 0 {
+		t.Fatalf("%d transactions are left in the mempool after mining", len(chain.Mempool()))
+	}
+}
+
+func TestAddBlockOrdersParentsFirst(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coin := matureCoins(chain, address, 1)[0]
+	parent := spendCoins(t, chain, w, address, 1, false, coin)
+	accept(t, chain, parent)
+	child := spendCoins(t, chain, w, address, 2, false, testCoin{parent, 0})
+	accept(t, chain, child)
+	coinbase := coinbaseTx(t, chain, address, "package")
+
+	if err := chain.AddBlock([]*Transaction{coinbase, child, parent}); err == nil {
+		t.Fatal("a block with a child before its parent was added")
+	}
+	overpaid, err := CoinbaseTx(address, "package", Coins(chain.Params.Reward)+4, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock([]*Transaction{overpaid, parent, child}); err == nil {
+		t.Fatal("a coinbase paying more than the package fees was added")
+	}
+	paid, err := CoinbaseTx(address, "package", Coins(chain.Params.Reward)+3, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock([]*Transaction{paid, parent, child}); err != nil {
+		t.Fatal(err)
+	}
+}
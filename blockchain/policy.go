@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"golang-blockchain/wallet"
+)
+
+// A Policy decides which valid transactions this node relays and keeps in
+// its mempool. Unlike the consensus rules it may differ between nodes, a
+// block holding a non-standard transaction is still valid.
+type Policy struct {
+	// DustThreshold is the smallest output value worth relaying.
+	DustThreshold Amount
+	MaxInputs     int
+	OutputTypes   []wallet.KeyType
+	// MinRelayFee is the fee per 1000 bytes a transaction must pay to be
+	// relayed, and a replacement must add on top of what it replaces.
+	MinRelayFee Amount
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		DustThreshold: 546,
+		MaxInputs:     100,
+		OutputTypes:   []wallet.KeyType{wallet.P256, wallet.Secp256k1, wallet.Ed25519, wallet.Schnorr},
+		MinRelayFee:   1000,
+	}
+}
+
+// A RejectError explains why a transaction was refused by policy. Reason is
+// a short code, Detail names the offending part.
+type RejectError struct {
+	Reason string
+	Detail string
+}
+
+func (e *RejectError) Error() string {
+	return e.Reason + ": " + e.Detail
+}
+
+func reject(reason, format string, a ...interface{}) error {
+	return &RejectError{Reason: reason, Detail: fmt.Sprintf(format, a...)}
+}
+
+// RelayFee is the minimum fee for size bytes.
+func (p *Policy) RelayFee(size int) Amount {
+	return rateFor(p.MinRelayFee, size)
+}
+
+func (p *Policy) allowsOutputType(keyType wallet.KeyType) bool {
+	for _, allowed := range p.OutputTypes {
+		if allowed == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckStandard applies the policy to a transaction that already passed
+// the consensus checks.
+func (p *Policy) CheckStandard(entry *MempoolEntry) error {
+	tx := entry.Tx
+	if tx.Version > TxVersion {
+		return reject("version", "transaction %x has version %d, only up to %d is relayed", tx.ID, tx.Version, TxVersion)
+	}
+	if len(tx.Inputs) > p.MaxInputs {
+		return reject("too-many-inputs", "transaction %x has %d inputs, at most %d are relayed", tx.ID, len(tx.Inputs), p.MaxInputs)
+	}
+	for i, output := range tx.Outputs {
+		if !p.allowsOutputType(output.KeyType) {
+			return reject("output-type", "output %d of %x is locked to a %s key, which is not relayed", i, tx.ID, output.KeyType)
+		}
+		if size := len(output.PubKeyHash); size != 20 && size != 32 {
+			return reject("output-type", "output %d of %x is locked to a %d byte hash", i, tx.ID, size)
+		}
+		if output.Value < p.DustThreshold {
+			return reject("dust", "output %d of %x pays %s, below the dust threshold of %s", i, tx.ID, output.Value, p.DustThreshold)
+		}
+	}
+	if minimum := p.RelayFee(entry.Size); entry.Fee < minimum {
+		return reject("min-relay-fee", "transaction %x pays a fee of %s, %d bytes need at least %s", tx.ID, entry.Fee, entry.Size, minimum)
+	}
+	return nil
+}
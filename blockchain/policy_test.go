@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"golang-blockchain/wallet"
+)
+
+func TestCheckStandard(t *testing.T) {
+	chain, _, address := newTestChain(t, 1)
+	policy := &Policy{
+		DustThreshold: 10,
+		MaxInputs:     2,
+		OutputTypes:   []wallet.KeyType{wallet.P256},
+		MinRelayFee:   1000,
+	}
+	entry := func(tx *Transaction, fee Amount) *MempoolEntry {
+		return &MempoolEntry{Tx: tx, Fee: fee, Size: len(tx.Serialize())}
+	}
+	standard := withValues(spendingTx(t, chain, 2, 1, address), 10)
+	size := Amount(len(standard.Serialize()))
+
+	otherType := withValues(spendingTx(t, chain, 1, 1, address), 10)
+	otherType.Outputs[0].KeyType = wallet.Ed25519
+	shortHash := withValues(spendingTx(t, chain, 1, 1, address), 10)
+	shortHash.Outputs[0].PubKeyHash = shortHash.Outputs[0].PubKeyHash[:8]
+	future := withValues(spendingTx(t, chain, 1, 1, address), 10)
+	future.Version = TxVersion + 1
+
+	tests := []struct {
+		name   string
+		entry  *MempoolEntry
+		reason string
+	}{
+		{"a standard transaction", entry(standard, size), ""},
+		{"a fee below the relay rate", entry(standard, size-1), "min-relay-fee"},
+		{"too many inputs", entry(withValues(spendingTx(t, chain, 3, 1, address), 10), 1000), "too-many-inputs"},
+		{"dust", entry(withValues(spendingTx(t, chain, 1, 2, address), 10, 9), 1000), "dust"},
+		{"an output type not relayed", entry(otherType, 1000), "output-type"},
+		{"a short hash", entry(shortHash, 1000), "output-type"},
+		{"an unknown version", entry(future, 1000), "version"},
+	}
+	for _, test := range tests {
+		err := policy.CheckStandard(test.entry)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		var rejection *RejectError
+		if !errors.As(err, &rejection) || rejection.Reason != test.reason {
+			t.Errorf("%s: got %v, want a %q rejection", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestMempoolAppliesPolicy(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	coins := matureCoins(chain, address, 2)
+	policy := DefaultPolicy()
+
+	cheap := spendCoins(t, chain, w, address, 1, true, coins[0])
+	minimum := policy.RelayFee(len(cheap.Serialize()))
+	if _, err := chain.AcceptToMempool(cheap, policy); err == nil {
+		t.Fatalf("a transaction paying 1 of the %s relay fee was accepted", minimum)
+	}
+	original := spendCoins(t, chain, w, address, minimum, true, coins[0])
+	if _, err := chain.AcceptToMempool(original, policy); err != nil {
+		t.Fatal(err)
+	}
+
+	// A replacement pays for its own relay on top of what it evicts.
+	if _, err := chain.AcceptToMempool(spendCoins(t, chain, w, address, minimum+1, true, coins[0]), policy); err == nil {
+		t.Fatal("a replacement adding less than the relay fee was accepted")
+	}
+	if _, err := chain.AcceptToMempool(spendCoins(t, chain, w, address, 2*minimum, true, coins[0]), policy); err != nil {
+		t.Fatal(err)
+	}
+
+	// Policy only guards the mempool, a block may still hold dust.
+	dust := spendCoins(t, chain, w, address, coins[1].tx.Outputs[0].Value, false, coins[1])
+	var rejection *RejectError
+	if _, err := chain.AcceptToMempool(dust, DefaultPolicy()); !errors.As(err, &rejection) || rejection.Reason != "dust" {
+		t.Fatalf("got %v, want a dust rejection", err)
+	}
+	coinbase, err := CoinbaseTx(address, "dust", Coins(chain.Params.Reward)+coins[1].tx.Outputs[0].Value, chain.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.AddBlock([]*Transaction{coinbase, dust}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,30 +6,40 @@ import (
 	"encoding/binary"
 	"math"
 	"math/big"
+	"sync/atomic"
 )
 
-const Difficulty = 12
-
 type ProofOfWork struct {
-	Block  *Block
-	Target *big.Int
+	Block      *Block
+	Target     *big.Int
+	Difficulty int
 }
 
-func NewProof(b *Block) *ProofOfWork {
+func NewProof(b *Block, difficulty int) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-Difficulty))
+	target.Lsh(target, uint(256-difficulty))
 
-	return &ProofOfWork{b, target}
+	return &ProofOfWork{b, target, difficulty}
 }
 
+// Blocks mined before timestamps and heights existed read as 0 for both and
+// keep the preimage they were mined with, every later block commits to them.
 func (pow *ProofOfWork) InitData(nonce int) []byte {
+	header := [][]byte{
+		pow.Block.PrevHash,
+		pow.Block.HashTransaction(),
+	}
+	if pow.Block.Timestamp != 0 {
+		header = append(header, ToHex(pow.Block.Timestamp))
+	}
+	if pow.Block.Height != 0 {
+		header = append(header, ToHex(int64(pow.Block.Height)))
+	}
 	data := bytes.Join(
-		[][]byte{
-			pow.Block.PrevHash,
-			pow.Block.HashTransaction(),
+		append(header,
 			ToHex(int64(nonce)),
-			ToHex(int64(Difficulty)),
-		},
+			ToHex(int64(pow.Difficulty)),
+		),
 		[]byte{},
 	)
 	return data
@@ -44,21 +54,37 @@ func ToHex(num int64) []byte {
 	return buff.Bytes()
 }
 
-func (pow *ProofOfWork) Run() (int, []byte) {
-	var intHash big.Int
-	var hash [32]byte
-	nonce := 0
-	for nonce < math.MaxInt64 {
-		data := pow.InitData(nonce)
-		hash = sha256.Sum256(data)
-		intHash.SetBytes(hash[:])
-		if intHash.Cmp(pow.Target) == -1 {
-			break
-		} else {
-			nonce++
-		}
+// Run searches for a nonce on threads goroutines, each trying every
+// threads-th nonce from its own start. A single thread finds the lowest one.
+func (pow *ProofOfWork) Run(threads int) (int, []byte) {
+	if threads < 1 {
+		threads = 1
 	}
-	return nonce, hash[:]
+
+	type solution struct {
+		nonce int
+		hash  []byte
+	}
+	found := make(chan solution, threads)
+	var done atomic.Bool
+	for start := 0; start < threads; start++ {
+		go func(nonce int) {
+			var intHash big.Int
+			for nonce < math.MaxInt64-threads && !done.Load() {
+				hash := sha256.Sum256(pow.InitData(nonce))
+				intHash.SetBytes(hash[:])
+				if intHash.Cmp(pow.Target) == -1 {
+					done.Store(true)
+					found <- solution{nonce, hash[:]}
+					return
+				}
+				nonce += threads
+			}
+		}(start)
+	}
+
+	s := <-found
+	return s.nonce, s.hash
 }
 
 func (pow *ProofOfWork) Validate() bool {
@@ -0,0 +1,43 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func testBlock() *Block {
+	return &Block{Transactions: []*Transaction{{ID: []byte("transaction")}}, PrevHash: []byte("previous")}
+}
+
+func TestProofOfWorkThreads(t *testing.T) {
+	const difficulty = 10
+	single := testBlock()
+	nonce, hash := NewProof(single, difficulty).Run(1)
+	single.Nonce, single.Hash = nonce, hash
+	if !NewProof(single, difficulty).Validate() {
+		t.Fatal("a single thread found a nonce that does not validate")
+	}
+	for lower := 0; lower < nonce; lower++ {
+		single.Nonce = lower
+		if NewProof(single, difficulty).Validate() {
+			t.Fatalf("a single thread found nonce %d, %d is lower and also valid", nonce, lower)
+		}
+	}
+
+	for _, threads := range []int{2, 4, 7} {
+		block := testBlock()
+		block.Nonce, block.Hash = NewProof(block, difficulty).Run(threads)
+		if !NewProof(block, difficulty).Validate() {
+			t.Fatalf("%d threads found a nonce that does not validate", threads)
+		}
+	}
+}
+
+func TestCreateBlockWithThreads(t *testing.T) {
+	block := CreateBlock(testBlock().Transactions, []byte("previous"), 1, 1700000000, 8, 3)
+	if !NewProof(block, 8).Validate() {
+		t.Fatal("a block mined on three threads does not validate")
+	}
+	if NewProof(block, 20).Validate() {
+		t.Fatal("a block mined at difficulty 8 passes difficulty 20")
+	}
+}
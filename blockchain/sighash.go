@@ -0,0 +1,254 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// TxVersion is the version new transactions are built with. From version 1
+// signatures commit to the version and to the value and lock of every
+// output spent, so a signer told the wrong amounts makes an invalid
+// signature instead of overspending. Version 0 keeps the original digest.
+// From amountVersion values are in base units rather than whole coins, and
+// from assetVersion transactions can issue and carry assets, which the
+// signatures commit to.
+const (
+	TxVersion     = 3
+	amountVersion = 2
+	assetVersion  = 3
+)
+
+// SigHashType selects what an input signature commits to. Inputs signed
+// before hash types existed carry zero and commit to everything without
+// committing to the flag itself.
+type SigHashType byte
+
+const (
+	SigHashAll          SigHashType = 1
+	SigHashNone         SigHashType = 2
+	SigHashSingle       SigHashType = 3
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+func (h SigHashType) base() SigHashType {
+	return h &^ SigHashAnyoneCanPay
+}
+
+func (h SigHashType) Valid() bool {
+	return h == 0 || (h.base() >= SigHashAll && h.base() <= SigHashSingle)
+}
+
+func (h SigHashType) String() string {
+	var name string
+	switch h.base() {
+	case 0:
+		return "legacy"
+	case SigHashAll:
+		name = "all"
+	case SigHashNone:
+		name = "none"
+	case SigHashSingle:
+		name = "single"
+	default:
+		return fmt.Sprintf("0x%02x", byte(h))
+	}
+	if h&SigHashAnyoneCanPay != 0 {
+		name += "|anyonecanpay"
+	}
+	return name
+}
+
+func ParseSigHashType(name string) (SigHashType, error) {
+	var h SigHashType
+	bases := 0
+	for _, part := range strings.Split(strings.ToLower(name), "|") {
+		switch part {
+		case "all":
+			h |= SigHashAll
+			bases++
+		case "none":
+			h |= SigHashNone
+			bases++
+		case "single":
+			h |= SigHashSingle
+			bases++
+		case "anyonecanpay":
+			h |= SigHashAnyoneCanPay
+		default:
+			return 0, fmt.Errorf("unknown signature hash type %q", part)
+		}
+	}
+	if bases != 1 || strings.Count(name, "|") > 1 {
+		return 0, fmt.Errorf("signature hash type %q needs exactly one of all, none or single", name)
+	}
+	return h, nil
+}
+
+// SigHash returns the digest input index signs under its hash type: none
+// drops the outputs, single keeps only the output at the same index and
+// anyone-can-pay drops the other inputs.
+func (t *Transaction) SigHash(index int, previousTx map[string]Transaction) ([]byte, error) {
+	if t.Version < 0 || t.Version > TxVersion {
+		return nil, fmt.Errorf("transaction %x has unknown version %d", t.ID, t.Version)
+	}
+	spent, err := t.spentOutputs(previousTx)
+	if err != nil {
+		return nil, err
+	}
+	hashType := t.Inputs[index].SigHash
+	if !hashType.Valid() || (t.Version > 0 && hashType == 0) {
+		return nil, fmt.Errorf("input %d has an unknown signature hash type %s", index, hashType)
+	}
+	if hashType.base() == SigHashSingle && index >= len(t.Outputs) {
+		return nil, fmt.Errorf("input %d signs single but there is no output %d", index, index)
+	}
+
+	if t.Version == 0 {
+		return t.legacySigHash(index, spent[index]), nil
+	}
+	return t.valueSigHash(index, spent), nil
+}
+
+func (t *Transaction) spentOutputs(previousTx map[string]Transaction) ([]TxOutput, error) {
+	spent := make([]TxOutput, len(t.Inputs))
+	for i, input := range t.Inputs {
+		previous, ok := previousTx[hex.EncodeToString(input.ID)]
+		if !ok || previous.ID == nil {
+			return nil, fmt.Errorf("transaction %x spent by input %d not found", input.ID, i)
+		}
+		if input.Out < 0 || input.Out >= len(previous.Outputs) {
+			return nil, fmt.Errorf("input %d spends missing output %x:%d", i, input.ID, input.Out)
+		}
+		spent[i] = previous.Outputs[input.Out]
+	}
+	return spent, nil
+}
+
+func (t *Transaction) legacySigHash(index int, spent TxOutput) []byte {
+	hashType := t.Inputs[index].SigHash
+	txCopy := t.TrimmedCopy()
+	txCopy.Inputs[index].PubKey = spent.PubKeyHash
+	txCopy.Inputs[index].SigHash = hashType
+
+	switch hashType.base() {
+	case SigHashNone:
+		txCopy.Outputs = nil
+	case SigHashSingle:
+		txCopy.Outputs = txCopy.Outputs[index : index+1]
+	}
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txCopy.Inputs = txCopy.Inputs[index : index+1]
+	}
+
+	hash := txCopy.Hash()
+	if hashType.base() == SigHashSingle {
+		digest := sha256.Sum256(binary.BigEndian.AppendUint32(hash, uint32(index)))
+		hash = digest[:]
+	}
+	return hash
+}
+
+// valueSigHash hashes fixed width fields instead of a gob copy: the version
+// and hash type, then unless anyone-can-pay the outpoints and replaceable
+// flags, values and locks of all inputs, then the outputs the hash type
+// covers, the asset issuance and finally the signing input with the output
+// it spends.
+func (t *Transaction) valueSigHash(index int, spent []TxOutput) []byte {
+	hashType := t.Inputs[index].SigHash
+	hasher := sha256.New()
+	hasher.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Version)))
+	hasher.Write([]byte{byte(hashType)})
+
+	if hashType&SigHashAnyoneCanPay == 0 {
+		prevouts, values, locks := sha256.New(), sha256.New(), sha256.New()
+		for i, input := range t.Inputs {
+			writeInput(prevouts, input)
+			writeValue(values, spent[i], t.Version)
+			writeLock(locks, spent[i])
+		}
+		hasher.Write(prevouts.Sum(nil))
+		hasher.Write(values.Sum(nil))
+		hasher.Write(locks.Sum(nil))
+	}
+
+	var covered []TxOutput
+	switch hashType.base() {
+	case SigHashAll:
+		covered = t.Outputs
+	case SigHashSingle:
+		covered = t.Outputs[index : index+1]
+	}
+	if covered != nil {
+		outputs := sha256.New()
+		for _, output := range covered {
+			writeOutput(outputs, output, t.Version)
+		}
+		hasher.Write(outputs.Sum(nil))
+	}
+	if t.Version >= assetVersion {
+		writeIssuance(hasher, t.Issuance)
+	}
+
+	writeInput(hasher, t.Inputs[index])
+	writeOutput(hasher, spent[index], t.Version)
+	hasher.Write(binary.BigEndian.AppendUint32(nil, uint32(index)))
+	return hasher.Sum(nil)
+}
+
+func writeInput(h hash.Hash, input TxInput) {
+	h.Write([]byte{byte(len(input.ID))})
+	h.Write(input.ID)
+	h.Write(binary.BigEndian.AppendUint32(nil, uint32(input.Out)))
+	if input.Replaceable {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+}
+
+func writeLock(h hash.Hash, output TxOutput) {
+	h.Write([]byte{byte(output.KeyType), byte(len(output.PubKeyHash))})
+	h.Write(output.PubKeyHash)
+}
+
+// writeValue writes the coins of an output, in whole coins before
+// amountVersion, and from assetVersion the asset it carries.
+func writeValue(h hash.Hash, output TxOutput, version int) {
+	unit := Amount(1)
+	if version < amountVersion {
+		unit = Coin
+	}
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(output.Value/unit)))
+	if version >= assetVersion {
+		h.Write([]byte{byte(len(output.Asset))})
+		h.Write(output.Asset)
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(output.AssetAmount)))
+	}
+}
+
+func writeOutput(h hash.Hash, output TxOutput, version int) {
+	writeValue(h, output, version)
+	writeLock(h, output)
+}
+
+func writeIssuance(h hash.Hash, issuance *AssetIssuance) {
+	if issuance == nil {
+		h.Write([]byte{0})
+		return
+	}
+	h.Write([]byte{1, byte(len(issuance.Asset))})
+	h.Write(issuance.Asset)
+	h.Write([]byte{byte(len(issuance.Name))})
+	h.Write([]byte(issuance.Name))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(issuance.Amount)))
+	if issuance.Mintable {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	writeLock(h, TxOutput{PubKeyHash: issuance.IssuerHash, KeyType: issuance.IssuerKeyType})
+}
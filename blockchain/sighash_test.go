@@ -0,0 +1,153 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"golang-blockchain/wallet"
+)
+
+func TestParseSigHashType(t *testing.T) {
+	for _, name := range []string{"all", "none", "single", "all|anyonecanpay", "none|anyonecanpay", "single|anyonecanpay"} {
+		h, err := ParseSigHashType(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if h.String() != name {
+			t.Errorf("%s parses to %s", name, h)
+		}
+	}
+	for _, name := range []string{"", "anyonecanpay", "all|none", "all|anyonecanpay|anyonecanpay", "everything"} {
+		if _, err := ParseSigHashType(name); err == nil {
+			t.Errorf("%q parsed", name)
+		}
+	}
+}
+
+func TestSigHashCommitments(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 2)
+	to := string(wallet.MakeWallet(wallet.P256).Address(chain.Params.AddressVersion))
+	base := NewTransaction(address, w.PublicKey, to, 30, 0, false, nil, chain)
+	if len(base.Inputs) != 1 || len(base.Outputs) != 2 {
+		t.Fatalf("the transaction has %d inputs and %d outputs", len(base.Inputs), len(base.Outputs))
+	}
+
+	var extra []byte
+	iterator := chain.Iterator()
+	for extra == nil {
+		coinbase := iterator.Next().Transactions[0]
+		if !bytes.Equal(coinbase.ID, base.Inputs[0].ID) {
+			extra = coinbase.ID
+		}
+	}
+	mutations := map[string]func(tx *Transaction){
+		"another output":  func(tx *Transaction) { tx.Outputs[1].Value-- },
+		"the same output": func(tx *Transaction) { tx.Outputs[0].Value-- },
+		"another input":   func(tx *Transaction) { tx.Inputs = append(tx.Inputs, TxInput{ID: extra, Out: 0}) },
+	}
+	tests := []struct {
+		hashType SigHashType
+		commits  map[string]bool
+	}{
+		{SigHashAll, map[string]bool{"another output": true, "the same output": true, "another input": true}},
+		{SigHashNone, map[string]bool{"another input": true}},
+		{SigHashSingle, map[string]bool{"the same output": true, "another input": true}},
+		{SigHashAll | SigHashAnyoneCanPay, map[string]bool{"another output": true, "the same output": true}},
+		{SigHashNone | SigHashAnyoneCanPay, map[string]bool{}},
+		{SigHashSingle | SigHashAnyoneCanPay, map[string]bool{"the same output": true}},
+	}
+
+	previous := chain.previousTransactions(&Transaction{Inputs: append(base.TrimmedCopy().Inputs, TxInput{ID: extra})})
+	for _, test := range tests {
+		for name, mutate := range mutations {
+			tx := base.TrimmedCopy()
+			tx.Inputs[0].SigHash = test.hashType
+			before, err := tx.SigHash(0, previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mutate(&tx)
+			after, err := tx.SigHash(0, previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := !bytes.Equal(before, after); changed != test.commits[name] {
+				t.Errorf("%s: changing %s changes the digest: %v", test.hashType, name, changed)
+			}
+		}
+	}
+}
+
+func TestSignedHashTypesVerify(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	to := string(wallet.MakeWallet(wallet.P256).Address(chain.Params.AddressVersion))
+
+	for _, hashType := range []SigHashType{SigHashAll, SigHashNone, SigHashSingle | SigHashAnyoneCanPay} {
+		tx := NewTransaction(address, w.PublicKey, to, 30, 0, false, nil, chain)
+		if signed := chain.SignTransaction(tx, *w, hashType); signed != len(tx.Inputs) {
+			t.Fatalf("%s: signed %d of %d inputs", hashType, signed, len(tx.Inputs))
+		}
+		if !chain.VerifyTransaction(tx) {
+			t.Fatalf("%s: the signed transaction does not verify", hashType)
+		}
+
+		tx.Outputs[1].Value--
+		if verified, free := chain.VerifyTransaction(tx), hashType.base() == SigHashNone || hashType.base() == SigHashSingle; verified != free {
+			t.Errorf("%s: after changing the change output the transaction verifies: %v", hashType, verified)
+		}
+	}
+
+	tx := NewTransaction(address, w.PublicKey, to, 30, 0, false, nil, chain)
+	chain.SignTransaction(tx, *w, SigHashSingle)
+	tx.Inputs[0].SigHash = 0x42
+	if chain.VerifyTransaction(tx) {
+		t.Error("an input with an unknown hash type verified")
+	}
+}
+
+func TestSignaturesCommitToSpentValues(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+	tx := NewTransaction(address, w.PublicKey, address, 30, 0, false, nil, chain)
+	previous := chain.previousTransactions(tx)
+
+	// A signer told the spent output is worth less signs another digest.
+	lied := make(map[string]Transaction)
+	for id, prev := range previous {
+		prev.Outputs = append([]TxOutput{}, prev.Outputs...)
+		for i := range prev.Outputs {
+			prev.Outputs[i].Value--
+		}
+		lied[id] = prev
+	}
+	tx.Sign(*w, SigHashAll, lied)
+	if chain.VerifyTransaction(tx) {
+		t.Fatal("a signature over the wrong spent values verified")
+	}
+	tx.Sign(*w, SigHashAll, previous)
+	if !chain.VerifyTransaction(tx) {
+		t.Fatal("a signature over the right spent values does not verify")
+	}
+
+	// Version 0 transactions keep the original digest, which does not
+	// cover the spent values, and have to sign under the legacy hash type.
+	legacy := NewTransaction(address, w.PublicKey, address, 30, 0, false, nil, chain)
+	legacy.Version = 0
+	legacy.Sign(*w, 0, lied)
+	if !chain.VerifyTransaction(legacy) {
+		t.Fatal("a version 0 signature does not verify")
+	}
+	legacy.Version = 1
+	if chain.VerifyTransaction(legacy) {
+		t.Fatal("a version 0 signature verified as version 1")
+	}
+
+	future := NewTransaction(address, w.PublicKey, address, 30, 0, false, nil, chain)
+	future.Version = TxVersion + 1
+	if _, err := future.SigHash(0, previous); err == nil {
+		t.Fatal("a transaction of an unknown version has a signature hash")
+	}
+}
@@ -2,35 +2,70 @@ package blockchain
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"fmt"
+	"golang-blockchain/params"
 	"golang-blockchain/wallet"
 	"log"
-	"math/big"
 	"strings"
 )
 
 type Transaction struct {
-	ID      []byte
-	Inputs  []TxInput
-	Outputs []TxOutput
+	ID       []byte
+	Inputs   []TxInput
+	Outputs  []TxOutput
+	Version  int
+	Issuance *AssetIssuance
 }
 
 func (t *Transaction) Serialize() []byte {
 	var buffer bytes.Buffer
 	encoder := gob.NewEncoder(&buffer)
-	err := encoder.Encode(t)
+	err := encoder.Encode(t.encoded())
 	if err != nil {
 		log.Fatal(err)
 	}
 	return buffer.Bytes()
 }
 
+func DeserializeTransaction(data []byte) Transaction {
+	var transaction Transaction
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&transaction)
+	Handle(err)
+	transaction.decoded()
+	return transaction
+}
+
+// encoded returns t as it is stored. Transactions before amountVersion
+// counted whole coins and are still stored that way, so their IDs and
+// signatures do not change.
+func (t *Transaction) encoded() *Transaction {
+	if t.Version >= amountVersion {
+		return t
+	}
+	legacy := *t
+	legacy.Outputs = make([]TxOutput, len(t.Outputs))
+	for i, output := range t.Outputs {
+		output.Value /= Coin
+		legacy.Outputs[i] = output
+	}
+	return &legacy
+}
+
+// decoded scales the whole coins of a stored legacy transaction to base
+// units.
+func (t *Transaction) decoded() {
+	if t.Version >= amountVersion {
+		return
+	}
+	for i := range t.Outputs {
+		t.Outputs[i].Value *= Coin
+	}
+}
+
 func (t *Transaction) Hash() []byte {
 	var hash [32]byte
 	copia := *t
@@ -39,32 +74,78 @@ func (t *Transaction) Hash() []byte {
 	return hash[:]
 }
 
-func (t *Transaction) Sign(privateKey ecdsa.PrivateKey, previousTx map[string]Transaction) {
+// Sign signs every input spending an output locked to the wallet's key and
+// returns how many it signed, leaving inputs of other keys alone.
+func (t *Transaction) Sign(w wallet.Wallet, hashType SigHashType, previousTx map[string]Transaction) int {
 	if t.IsCoinbase() {
-		return
+		return 0
 	}
 
-	for _, input := range t.Inputs {
-		id := hex.EncodeToString(input.ID)
-		if previousTx[id].ID == nil {
-			log.Fatal("Transaction not found, Previous Transaction is not valid")
-		}
+	publicKey := w.PublicKey
+	if w.Type == wallet.P256 {
+		key, err := wallet.ParseLegacyPublicKey(publicKey)
+		Handle(err)
+		publicKey = wallet.EncodePublicKey(key)
 	}
 
-	txCopy := t.TrimmedCopy()
+	signed := 0
 	for inputId, input := range t.Inputs {
-		id := hex.EncodeToString(input.ID)
-		previousTx := previousTx[id]
-		txCopy.Inputs[inputId].Signature = nil
-		txCopy.Inputs[inputId].PubKey = previousTx.Outputs[input.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inputId].PubKey = nil
-
-		r, s, err := ecdsa.Sign(rand.Reader, &privateKey, txCopy.ID)
+		previous, ok := previousTx[hex.EncodeToString(input.ID)]
+		if !ok || previous.ID == nil {
+			log.Fatal("Transaction not found, Previous Transaction is not valid")
+		}
+		output := previous.Outputs[input.Out]
+		if output.KeyType != w.Type || !wallet.MatchesPublicKey(output.PubKeyHash, publicKey) {
+			continue
+		}
+
+		t.Inputs[inputId].PubKey = publicKey
+		t.Inputs[inputId].SigHash = hashType
+		hash, err := t.SigHash(inputId, previousTx)
+		Handle(err)
+		signature, err := w.Sign(hash)
 		Handle(err)
-		signature := append(r.Bytes(), s.Bytes()...)
 		t.Inputs[inputId].Signature = signature
+		signed++
+	}
+	return signed
+}
+
+// SigHashes returns the digest each input signs under its hash type.
+func (t *Transaction) SigHashes(previousTx map[string]Transaction) [][]byte {
+	var hashes [][]byte
+	for inputId := range t.Inputs {
+		hash, err := t.SigHash(inputId, previousTx)
+		Handle(err)
+		hashes = append(hashes, hash)
 	}
+	return hashes
+}
+
+// InputValue sums the outputs the inputs spend.
+func (t *Transaction) InputValue(previousTx map[string]Transaction) Amount {
+	spent, err := t.spentOutputs(previousTx)
+	Handle(err)
+	total, err := sumValues(spent)
+	Handle(err)
+	return total
+}
+
+// OutputValue sums the outputs, which CheckTransactionLimits keeps in range.
+func (t *Transaction) OutputValue() Amount {
+	var total Amount
+	for _, output := range t.Outputs {
+		total += output.Value
+	}
+	return total
+}
+
+func sumValues(outputs []TxOutput) (Amount, error) {
+	var amounts []Amount
+	for _, output := range outputs {
+		amounts = append(amounts, output.Value)
+	}
+	return SumAmounts(amounts...)
 }
 
 func (t *Transaction) TrimmedCopy() Transaction {
@@ -73,120 +154,172 @@ func (t *Transaction) TrimmedCopy() Transaction {
 
 	for _, input := range t.Inputs {
 		txI := TxInput{
-			ID:        input.ID,
-			Out:       input.Out,
-			PubKey:    nil,
-			Signature: nil,
+			ID:          input.ID,
+			Out:         input.Out,
+			PubKey:      nil,
+			Signature:   nil,
+			Replaceable: input.Replaceable,
 		}
 		inputs = append(inputs, txI)
 	}
 
 	for _, output := range t.Outputs {
 		txO := TxOutput{
-			Value:      output.Value,
-			PubKeyHash: output.PubKeyHash,
+			Value:       output.Value,
+			PubKeyHash:  output.PubKeyHash,
+			KeyType:     output.KeyType,
+			Asset:       output.Asset,
+			AssetAmount: output.AssetAmount,
 		}
 		outputs = append(outputs, txO)
 	}
 	return Transaction{
-		ID:      t.ID,
-		Inputs:  inputs,
-		Outputs: outputs,
+		ID:       t.ID,
+		Inputs:   inputs,
+		Outputs:  outputs,
+		Version:  t.Version,
+		Issuance: t.Issuance,
 	}
 }
 
-func (t *Transaction) Verify(previousTxs map[string]Transaction) bool {
+// Verify checks every input. Schnorr signatures are only parsed and queued
+// when a batch is given, the caller verifies the whole batch at once.
+func (t *Transaction) Verify(previousTxs map[string]Transaction, strict bool, batch *wallet.SchnorrBatch) error {
 	if t.IsCoinbase() {
-		return true
+		return nil
 	}
 
-	for _, input := range t.Inputs {
-		id := hex.EncodeToString(input.ID)
-		if previousTxs[id].ID == nil {
-			log.Fatal("Transaction not found, Previous Transaction is not valid")
-		}
+	spent, err := t.spentOutputs(previousTxs)
+	if err != nil {
+		return err
+	}
+	in, err := sumValues(spent)
+	if err != nil {
+		return fmt.Errorf("the outputs spent by %x: %w", t.ID, err)
+	}
+	out, err := sumValues(t.Outputs)
+	if err != nil {
+		return fmt.Errorf("the outputs of %x: %w", t.ID, err)
+	}
+	if out > in {
+		return fmt.Errorf("transaction %x spends %s but pays out %s", t.ID, in, out)
+	}
+	err = t.checkAssetBalances(spent)
+	if err != nil {
+		return err
 	}
 
-	txCopy := t.TrimmedCopy()
-	curva := elliptic.P256()
 	for inputID, input := range t.Inputs {
-		id := hex.EncodeToString(input.ID)
-		previousTx := previousTxs[id]
-		txCopy.Inputs[inputID].Signature = nil
-		txCopy.Inputs[inputID].PubKey = previousTx.Outputs[input.Out].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Inputs[inputID].PubKey = nil
-
-		r := big.Int{}
-		s := big.Int{}
-		signatureLength := len(input.Signature)
-		r.SetBytes(input.Signature[:signatureLength/2])
-		s.SetBytes(input.Signature[signatureLength/2:])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLength := len(input.PubKey)
-		x.SetBytes(input.PubKey[:keyLength/2])
-		y.SetBytes(input.PubKey[keyLength/2:])
-
-		pubKey := ecdsa.PublicKey{
-			Curve: curva,
-			X:     &x,
-			Y:     &y,
+		output := spent[inputID]
+		if !input.UsesKey(output.PubKeyHash) {
+			return fmt.Errorf("input %d of %x does not carry the key of the output it spends", inputID, t.ID)
+		}
+		hash, err := t.SigHash(inputID, previousTxs)
+		if err != nil {
+			return err
 		}
-		if !ecdsa.Verify(&pubKey, txCopy.ID, &r, &s) {
-			return false
+
+		if batch != nil && output.KeyType == wallet.Schnorr {
+			err = batch.Add(input.PubKey, hash, input.Signature)
+		} else {
+			var scheme wallet.Scheme
+			scheme, err = wallet.SchemeFor(output.KeyType)
+			if err == nil {
+				err = scheme.Verify(input.PubKey, hash, input.Signature, strict)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("input %d of %x: %w", inputID, t.ID, err)
 		}
 	}
-	return true
+	return nil
 }
-func NewTransaction(from, to string, amount int, chain *BlockChain) *Transaction {
+
+// Replaceable reports whether any input signals that the transaction may be
+// replaced in the mempool by one paying a higher fee.
+func (t *Transaction) Replaceable() bool {
+	for _, input := range t.Inputs {
+		if input.Replaceable {
+			return true
+		}
+	}
+	return false
+}
+
+func NewTransaction(from string, publicKey []byte, to string, amount, fee Amount, replaceable bool, changeAddress func() string, chain *BlockChain) *Transaction {
 	var inputs []TxInput
 	var outputs []TxOutput
 
-	wallets, err := wallet.CreateWallets()
+	addr, publicKey := spendingKey(from, publicKey, chain.Params)
+	total, err := SumAmounts(amount, fee)
 	Handle(err)
+	saldo, validOutputs := chain.FindSpendableOutputs(addr.Hash, total)
+	if saldo < total {
+		fmt.Printf("O usuario so tem %s de saldo", saldo)
+		log.Panic("Error: not enough funds for this transaction")
+	}
+	inputs = spendingInputs(validOutputs, publicKey, replaceable)
 
-	w := wallets.GetWallet(from)
-	if w.PrivateKey.D == nil {
-		log.Fatal("Wallet not found")
+	if len(inputs) > chain.Params.MaxTxInputs {
+		log.Panicf("Error: this transaction needs %d inputs, the limit is %d", len(inputs), chain.Params.MaxTxInputs)
 	}
-	publicKey := wallet.PublicKeyHash(w.PublicKey)
-	saldo, validOutputs := chain.FindSpendableOutputs(publicKey, amount)
-	if saldo < amount {
-		fmt.Printf("O usuario so tem %d de saldo", saldo)
-		log.Panic("Error: not enough funds for this transaction")
+
+	txOut, err := NewTxOutput(amount, to, chain.Params)
+	Handle(err)
+	outputs = append(outputs, *txOut)
+	if saldo > total {
+		change := from
+		if changeAddress != nil {
+			change = changeAddress()
+		}
+		txOut, err := NewTxOutput(saldo-total, change, chain.Params)
+		Handle(err)
+		outputs = append(outputs, *txOut)
 	}
+	transaction := Transaction{
+		Inputs:  inputs,
+		Outputs: outputs,
+		Version: TxVersion,
+	}
+	transaction.ID = transaction.Hash()
 
+	return &transaction
+}
+
+// spendingKey decodes the address inputs spend from and the key they carry,
+// legacy P256 wallet keys are stored in another encoding.
+func spendingKey(from string, publicKey []byte, p *params.Params) (*wallet.Address, []byte) {
+	addr, err := wallet.DecodeAddress(from, p)
+	Handle(err)
+	if addr.Key != wallet.P256 {
+		return addr, publicKey
+	}
+	key, err := wallet.ParseLegacyPublicKey(publicKey)
+	Handle(err)
+	return addr, wallet.EncodePublicKey(key)
+}
+
+// spendingInputs builds unsigned inputs for the outputs FindSpendableOutputs
+// selected.
+func spendingInputs(validOutputs map[string][]int, publicKey []byte, replaceable bool) []TxInput {
+	var inputs []TxInput
 	for id, outputs := range validOutputs {
 		id, err := hex.DecodeString(id)
 		Handle(err)
 
 		for _, output := range outputs {
 			input := TxInput{
-				ID:        id,
-				Out:       output,
-				Signature: nil,
-				PubKey:    w.PublicKey,
+				ID:          id,
+				Out:         output,
+				Signature:   nil,
+				PubKey:      publicKey,
+				SigHash:     SigHashAll,
+				Replaceable: replaceable,
 			}
 			inputs = append(inputs, input)
 		}
 	}
-
-	txOut := *NewTxOutput(amount, to)
-	outputs = append(outputs, txOut)
-	if saldo > amount {
-		txOut := *NewTxOutput(saldo-amount, from)
-		outputs = append(outputs, txOut)
-	}
-	transaction := Transaction{
-		Inputs:  inputs,
-		Outputs: outputs,
-	}
-	transaction.ID = transaction.Hash()
-	chain.SignTransaction(&transaction, w.PrivateKey)
-
-	return &transaction
+	return inputs
 }
 
 func (tx *Transaction) SetID() {
@@ -201,20 +334,23 @@ func (tx *Transaction) SetID() {
 	tx.ID = hash[:]
 }
 
-func CoinbaseTx(to, data string) *Transaction {
+func CoinbaseTx(to, data string, reward Amount, p *params.Params) (*Transaction, error) {
 	if data == "" {
 		data = fmt.Sprintf("Coins to %s", to)
 	}
 
-	txin := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txout := NewTxOutput(100, to)
+	txin := TxInput{ID: []byte{}, Out: -1, PubKey: []byte(data)}
+	txout, err := NewTxOutput(reward, to, p)
+	if err != nil {
+		return nil, err
+	}
 	tx := Transaction{
-		nil,
-		[]TxInput{txin},
-		[]TxOutput{*txout},
+		Inputs:  []TxInput{txin},
+		Outputs: []TxOutput{*txout},
+		Version: TxVersion,
 	}
 	tx.SetID()
-	return &tx
+	return &tx, nil
 }
 
 func (tx *Transaction) IsCoinbase() bool {
@@ -230,18 +366,37 @@ func (tx Transaction) String() string {
 	var lines []string
 
 	lines = append(lines, fmt.Sprintf("--- Transaction %x:", tx.ID))
+	lines = append(lines, fmt.Sprintf("     Version: %d", tx.Version))
 	for i, input := range tx.Inputs {
 		lines = append(lines, fmt.Sprintf("     Input %d:", i))
 		lines = append(lines, fmt.Sprintf("       TXID:     %x", input.ID))
 		lines = append(lines, fmt.Sprintf("       Out:       %d", input.Out))
 		lines = append(lines, fmt.Sprintf("       Signature: %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("       PubKey:    %x", input.PubKey))
+		lines = append(lines, fmt.Sprintf("       SigHash:   %s", input.SigHash))
+		if input.Replaceable {
+			lines = append(lines, "       Replaceable")
+		}
 	}
 
 	for i, output := range tx.Outputs {
 		lines = append(lines, fmt.Sprintf("     Output %d:", i))
-		lines = append(lines, fmt.Sprintf("       Value:  %d", output.Value))
+		lines = append(lines, fmt.Sprintf("       Value:  %s", output.Value))
 		lines = append(lines, fmt.Sprintf("       PubKeyHash: %x", output.PubKeyHash))
+		if len(output.Asset) > 0 {
+			lines = append(lines, fmt.Sprintf("       Asset:  %x", output.Asset))
+			lines = append(lines, fmt.Sprintf("       Amount: %s", output.AssetAmount))
+		}
+	}
+
+	if issuance := tx.Issuance; issuance != nil {
+		lines = append(lines, "     Issuance:")
+		lines = append(lines, fmt.Sprintf("       Asset:    %x", issuance.Asset))
+		if issuance.Name != "" {
+			lines = append(lines, fmt.Sprintf("       Name:     %q", issuance.Name))
+			lines = append(lines, fmt.Sprintf("       Mintable: %t", issuance.Mintable))
+		}
+		lines = append(lines, fmt.Sprintf("       Amount:   %s", issuance.Amount))
 	}
 
 	return strings.Join(lines, "\n")
@@ -2,46 +2,57 @@ package blockchain
 
 import (
 	"bytes"
-	"fmt"
+
+	"golang-blockchain/params"
 	"golang-blockchain/wallet"
 )
 
+// A TxOutput pays Value coins to a lock. From assetVersion it can also
+// carry AssetAmount units of the asset with ID Asset, the coins then only
+// make it worth relaying.
 type TxOutput struct {
-	Value      int
-	PubKeyHash []byte
+	Value       Amount
+	PubKeyHash  []byte
+	KeyType     wallet.KeyType
+	Asset       []byte
+	AssetAmount Amount
 }
 
 type TxInput struct {
-	ID        []byte
-	Out       int
-	Signature []byte
-	PubKey    []byte
+	ID          []byte
+	Out         int
+	Signature   []byte
+	PubKey      []byte
+	SigHash     SigHashType
+	Replaceable bool
 }
 
 func (txin *TxInput) UsesKey(pubkeyHash []byte) bool {
-	lockHash := wallet.PublicKeyHash(txin.PubKey)
-	return bytes.Equal(lockHash, pubkeyHash)
+	return wallet.MatchesPublicKey(pubkeyHash, txin.PubKey)
 }
 
-func (txout *TxOutput) Lock(address []byte) {
-	publicKeyHash := wallet.Base58Decode(address)
-	checksum := len(publicKeyHash) - 4
-	publicKeyHash = publicKeyHash[1:checksum]
-	txout.PubKeyHash = publicKeyHash
+func (txout *TxOutput) Lock(address string, p *params.Params) error {
+	addr, err := wallet.DecodeAddress(address, p)
+	if err != nil {
+		return err
+	}
+	txout.PubKeyHash = addr.Hash
+	txout.KeyType = addr.Key
+	return nil
 }
 
 func (txout *TxOutput) IsLocked(pubKeyHash []byte) bool {
-	fmt.Printf("txout.PubKeyHash: %x\n", txout.PubKeyHash)
-	fmt.Printf("pubKeyHash:       %x\n", pubKeyHash)
-
 	return bytes.Equal(txout.PubKeyHash, pubKeyHash)
 }
 
-func NewTxOutput(value int, address string) *TxOutput {
+func NewTxOutput(value Amount, address string, p *params.Params) (*TxOutput, error) {
 	txo := &TxOutput{
 		Value:      value,
 		PubKeyHash: nil,
 	}
-	txo.Lock([]byte(address))
-	return txo
+	err := txo.Lock(address, p)
+	if err != nil {
+		return nil, err
+	}
+	return txo, nil
 }
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+	"golang-blockchain/wallet"
+)
+
+func TestNewTxOutputChecksTheAddress(t *testing.T) {
+	w := wallet.MakeWallet(wallet.P256)
+	output, err := NewTxOutput(5, string(w.Address(params.RegTest.AddressVersion)), &params.RegTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.PubKeyHash, wallet.PublicKeyHash(w.PublicKey)) || output.Value != 5 {
+		t.Fatalf("the output pays %d to %x", output.Value, output.PubKeyHash)
+	}
+
+	mainnet := string(w.Address(params.MainNet.AddressVersion))
+	if _, err := NewTxOutput(5, mainnet, &params.RegTest); !errors.Is(err, wallet.ErrAddressNetwork) {
+		t.Errorf("paying a mainnet address on regtest: got %v, want %v", err, wallet.ErrAddressNetwork)
+	}
+	if _, err := NewTxOutput(5, "not an address", &params.RegTest); err == nil {
+		t.Error("paid an address that does not decode")
+	}
+	if _, err := CoinbaseTx(mainnet, "", 100, &params.RegTest); err == nil {
+		t.Error("a coinbase paid a mainnet address on regtest")
+	}
+}
+
+func TestStrictSignatureHeight(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+
+	tx := NewTransaction(address, w.PublicKey, address, 30, 0, false, nil, chain)
+	signTransaction(t, chain, tx, w)
+	// The signature does not cover the input key, so the key can be swapped
+	// for the encoding older builds wrote.
+	key, err := wallet.ParsePublicKey(w.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range tx.Inputs {
+		tx.Inputs[i].PubKey = wallet.LegacyPublicKey(key)
+	}
+
+	chain.Params.StrictSigHeight = chain.GetBestHeight() + 2
+	if !chain.VerifyTransaction(tx) {
+		t.Fatal("a legacy key was rejected before the strict height")
+	}
+	chain.Params.StrictSigHeight = chain.GetBestHeight() + 1
+	if chain.VerifyTransaction(tx) {
+		t.Fatal("a legacy key was accepted at the strict height")
+	}
+}
+
+func TestSpendFromEveryKeyType(t *testing.T) {
+	chain, w, address := newTestChain(t, 1)
+	chain.Generate(address, 1)
+
+	for _, keyType := range []wallet.KeyType{wallet.Secp256k1, wallet.Ed25519} {
+		owner := wallet.MakeWallet(keyType)
+		to := string(owner.Address(chain.Params.AddressVersion))
+		tx := NewTransaction(address, w.PublicKey, to, 10, 0, false, nil, chain)
+		signTransaction(t, chain, tx, w)
+		if err := chain.AddBlock([]*Transaction{tx}); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Outputs[0].KeyType != keyType {
+			t.Fatalf("the output to a %s address is locked to %s", keyType, tx.Outputs[0].KeyType)
+		}
+
+		spend := NewTransaction(to, owner.PublicKey, address, 10, 0, false, nil, chain)
+		signTransaction(t, chain, spend, owner)
+		// A key of another type does not unlock the output.
+		spend.Inputs[0].PubKey = w.PublicKey
+		if chain.VerifyTransaction(spend) {
+			t.Fatalf("a %s output was spent with a P256 key", keyType)
+		}
+	}
+}
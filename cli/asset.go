@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+
+	"golang-blockchain/blockchain"
+	"golang-blockchain/wallet"
+)
+
+func parseAssetID(id string) []byte {
+	asset, err := hex.DecodeString(id)
+	if err != nil || len(asset) != 32 {
+		log.Fatalf("Invalid asset ID %q, expected 64 hex characters", id)
+	}
+	return asset
+}
+
+// spendFromWallet builds a transaction spending from a wallet address, then
+// signs it and queues it in the mempool.
+func (cli *CommandLine) spendFromWallet(from string, build func(chain *blockchain.BlockChain, publicKey []byte, changeAddress func() string) *blockchain.Transaction) *blockchain.Transaction {
+	wallets := cli.loadWallets()
+	w, ok := wallets.Wallets[from]
+	if !ok {
+		log.Fatal("Wallet not found")
+	}
+
+	chain := blockchain.ContinueBlockChain(from, cli.params)
+	defer chain.Database.Close()
+
+	tx := build(chain, w.PublicKey, cli.changeAddress(wallets))
+	cli.unlockWallets(wallets)
+	chain.SignTransaction(tx, wallets.GetWallet(from), blockchain.SigHashAll)
+	cli.submitTransaction(chain, tx)
+	err := wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	return tx
+}
+
+func (cli *CommandLine) issueAsset(from, name string, supply, fee blockchain.Amount, mintable bool) {
+	from = cli.walletAddress(from)
+	dust := cli.config.Policy().DustThreshold
+	issuance := blockchain.AssetIssuance{Name: name, Amount: supply, Mintable: mintable}
+
+	tx := cli.spendFromWallet(from, func(chain *blockchain.BlockChain, publicKey []byte, changeAddress func() string) *blockchain.Transaction {
+		// Minting needs an input of the issuer, so its change stays there.
+		if mintable {
+			changeAddress = nil
+		}
+		return blockchain.NewIssuanceTransaction(from, publicKey, issuance, fee, dust, true, changeAddress, chain)
+	})
+	supplyKind := "a fixed supply"
+	if mintable {
+		supplyKind = "a mintable supply"
+	}
+	fmt.Printf("Issued %s of asset %q with %s to %s\n", supply, name, supplyKind, from)
+	fmt.Printf("Asset ID: %x\n", tx.Issuance.Asset)
+}
+
+func (cli *CommandLine) mintAsset(from, assetID string, amount, fee blockchain.Amount) {
+	from = cli.walletAddress(from)
+	asset := parseAssetID(assetID)
+	dust := cli.config.Policy().DustThreshold
+
+	cli.spendFromWallet(from, func(chain *blockchain.BlockChain, publicKey []byte, _ func() string) *blockchain.Transaction {
+		info := chain.Assets()[hex.EncodeToString(asset)]
+		if info == nil {
+			log.Fatalf("Asset %x has not been issued in the chain", asset)
+		}
+		if !info.Mintable {
+			log.Fatalf("Asset %x (%s) has a fixed supply", asset, info.Name)
+		}
+		addr := cli.validateAddress(from)
+		if addr.Key != info.IssuerKeyType || !bytes.Equal(addr.Hash, info.IssuerHash) {
+			log.Fatalf("Only the issuer of asset %x (%s) can mint more of it", asset, info.Name)
+		}
+		issuance := blockchain.AssetIssuance{Asset: asset, Amount: amount}
+		return blockchain.NewIssuanceTransaction(from, publicKey, issuance, fee, dust, true, nil, chain)
+	})
+	fmt.Printf("Minted %s of asset %x to %s\n", amount, asset, from)
+}
+
+func (cli *CommandLine) sendAsset(from, to, assetID string, amount, fee blockchain.Amount, replaceable bool) {
+	from = cli.walletAddress(from)
+	if cli.validateAddress(to).Type != wallet.PubKeyHashAddress {
+		log.Fatalf("Cannot send to %s: script-hash outputs are not spendable on this chain yet", to)
+	}
+	asset := parseAssetID(assetID)
+	dust := cli.config.Policy().DustThreshold
+
+	cli.spendFromWallet(from, func(chain *blockchain.BlockChain, publicKey []byte, changeAddress func() string) *blockchain.Transaction {
+		return blockchain.NewAssetTransaction(from, publicKey, to, asset, amount, fee, dust, replaceable, changeAddress, chain)
+	})
+}
+
+// coinBalance sums the plain coins among outputs. Asset outputs are left
+// out, their coins only pay for relaying the asset and move with it.
+func coinBalance(outputs []blockchain.TxOutput) blockchain.Amount {
+	var balance blockchain.Amount
+	for _, output := range outputs {
+		if len(output.Asset) == 0 {
+			balance += output.Value
+		}
+	}
+	return balance
+}
+
+// assetBalances sums the assets an address holds by hex ID.
+func (cli *CommandLine) assetBalances(chain *blockchain.BlockChain, address string) map[string]blockchain.Amount {
+	balances := make(map[string]blockchain.Amount)
+	for _, UTXO := range chain.FindUTXO(cli.validateAddress(address).Hash) {
+		if len(UTXO.Asset) > 0 {
+			balances[hex.EncodeToString(UTXO.Asset)] += UTXO.AssetAmount
+		}
+	}
+	return balances
+}
+
+func sortedAssetIDs(balances map[string]blockchain.Amount) []string {
+	var ids []string
+	for id := range balances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// getAssetBalance lists the assets held by an address, or by every wallet
+// address followed by the wallet's totals.
+func (cli *CommandLine) getAssetBalance(address string) {
+	var addresses []string
+	if address != "" {
+		cli.validateAddress(address)
+		addresses = []string{address}
+	} else {
+		addresses = cli.walletAddresses(cli.loadWallets())
+	}
+
+	chain := blockchain.ContinueBlockChain(address, cli.params)
+	defer chain.Database.Close()
+
+	assets := chain.Assets()
+	name := func(id string) string {
+		if info := assets[id]; info != nil {
+			return info.Name
+		}
+		return "unknown"
+	}
+
+	totals := make(map[string]blockchain.Amount)
+	for _, address := range addresses {
+		balances := cli.assetBalances(chain, address)
+		if len(balances) == 0 {
+			continue
+		}
+		fmt.Printf("Assets of %s:\n", address)
+		for _, id := range sortedAssetIDs(balances) {
+			fmt.Printf(" %s %-20q %s\n", id, name(id), balances[id])
+			totals[id] += balances[id]
+		}
+	}
+	if len(addresses) > 1 {
+		fmt.Println("Total:")
+		for _, id := range sortedAssetIDs(totals) {
+			fmt.Printf(" %s %-20q %s\n", id, name(id), totals[id])
+		}
+	}
+}
+
+func (cli *CommandLine) listAssets() {
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	assets := chain.Assets()
+	var ids []string
+	for id := range assets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		info := assets[id]
+		supplyKind := "fixed"
+		if info.Mintable {
+			supplyKind = "mintable"
+		}
+		fmt.Printf("%s %q\n", id, info.Name)
+		fmt.Printf("  Supply: %s (%s)\n", info.Supply, supplyKind)
+		fmt.Printf("  Issued in: %x\n", info.IssueTx)
+		if info.Mintable {
+			issuer := wallet.Address{Type: wallet.PubKeyHashAddress, Key: info.IssuerKeyType, Hash: info.IssuerHash}
+			fmt.Printf("  Issuer: %s\n", issuer.Encode(cli.params))
+		}
+	}
+	fmt.Printf("%d assets\n", len(assets))
+}
@@ -0,0 +1,18 @@
+package cli
+
+import (
+	"testing"
+
+	"golang-blockchain/blockchain"
+)
+
+func TestCoinBalanceLeavesOutAssets(t *testing.T) {
+	outputs := []blockchain.TxOutput{
+		{Value: blockchain.Coins(2)},
+		{Value: 546, Asset: make([]byte, 32), AssetAmount: 1000},
+		{Value: blockchain.Coin / 2},
+	}
+	if balance := coinBalance(outputs); balance != blockchain.Coins(2)+blockchain.Coin/2 {
+		t.Fatalf("coin balance is %s, want 2.5", balance)
+	}
+}
@@ -1,63 +1,579 @@
 package cli
 
 import (
+	"bufio"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"golang-blockchain/blockchain"
+	"golang-blockchain/params"
 	"golang-blockchain/wallet"
 )
 
-type CommandLine struct{}
+type CommandLine struct {
+	config *Config
+	params *params.Params
+	args   []string
+}
 
 func (cli *CommandLine) printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println(" getbalance -address ADDRESS - get the balance for an address")
+	fmt.Println("Usage: [--config FILE] [--network NETWORK] [--datadir DIR] [OPTIONS] COMMAND")
+	fmt.Println(" --config FILE - TOML config file (default ./blockchain.toml, or $BLOCKCHAIN_CONFIG)")
+	fmt.Println(" --network NETWORK - mainnet (default), testnet or regtest")
+	fmt.Println(" --datadir DIR - directory holding the blocks database and wallet file")
+	fmt.Println(" --miningaddress, --minerthreads - see config show")
+	fmt.Println(" --dustthreshold, --maxinputs, --outputtypes, --minrelayfee - the relay policy for the mempool, see config show")
+	fmt.Println(" Every option can also be set in the config file or as BLOCKCHAIN_<OPTION>")
+	fmt.Println("Commands:")
+	fmt.Println(" getbalance [-address ADDRESS] - get the coin balance for an address, or for every wallet address, assets are in getassetbalance")
+	fmt.Println(" history [-address ADDRESS] - list the transactions of an address, or of every wallet address")
+	fmt.Println(" importaddress [-address ADDRESS] [-pubkey HEX] - watch an address without its private key")
 	fmt.Println(" createblockchain -address ADDRESS creates a blockchain and sends genesis reward to address")
 	fmt.Println(" printchain - Prints the blocks in the chain")
-	fmt.Println(" send -from FROM -to TO -amount AMOUNT - Send amount of coins")
-	fmt.Println(" createwallet - Creates a new Wallet")
-	fmt.Println(" listaddresses - Lists the addresses in our wallet file")
+	fmt.Println(" send -from FROM -to TO -amount AMOUNT [-fee FEE] [-replaceable=false] [-sighash TYPE] [-mine] - Queues a payment in the mempool, -mine also mines it into a block at once with the reward going to FROM")
+	fmt.Println(" signrawtransaction -tx HEX -address ADDRESS [-sighash TYPE] - Signs the inputs of a transaction that spend from address")
+	fmt.Println(" sendrawtransaction -tx HEX - Verifies a signed transaction and queues it in the mempool")
+	fmt.Println("  TYPE is all, none or single, optionally with |anyonecanpay (default all)")
+	fmt.Println(" createwallet [-label LABEL] [-passphrase] [-bech32] [-type p256|secp256k1|ed25519|schnorr] - Derives the next receive address from the wallet's HD seed, other key types get a random key")
+	fmt.Println(" getpubkey -address ADDRESS - Prints the key type and public key of a wallet address")
+	fmt.Println(" musigaddress -pubkeys HEX,HEX,... - Aggregates schnorr keys into one address and watches it")
+	fmt.Println(" musiginit -pubkeys HEX,HEX,... -to TO -amount AMOUNT [-fee FEE] -session FILE - Starts a signing session spending from the aggregate address")
+	fmt.Println(" musignonce -session FILE -address ADDRESS - Adds this signer's nonces to the session")
+	fmt.Println(" musigsign -session FILE -address ADDRESS - Adds this signer's partial signature once every nonce is in")
+	fmt.Println(" musigfinish -session FILE - Combines the partial signatures and sends the transaction")
+	fmt.Println(" exportkey -address ADDRESS - Prints the private key of address in a portable format")
+	fmt.Println(" importkey - Imports a private key and rescans the chain for its outputs")
+	fmt.Println(" convertaddress -address ADDRESS - Prints the Base58 and Bech32 forms of an address")
+	fmt.Println(" signmessage -address ADDRESS -message MESSAGE - Signs a message with the key of address")
+	fmt.Println(" verifymessage -address ADDRESS -signature SIGNATURE -message MESSAGE - Checks a signed message")
+	fmt.Println(" dumpmnemonic - Prints the mnemonic that backs up the wallet's HD seed")
+	fmt.Println(" restorewallet [-passphrase] [-gap N] - Rebuilds the wallet from a mnemonic and rescans the chain")
+	fmt.Println(" discoveraddresses -gap N - Finds used HD addresses on the chain, stopping after N unused ones")
+	fmt.Println(" listaddresses [-sort FIELD] [-label TEXT] [-purpose PURPOSE] - Lists wallet addresses with their metadata and balance")
+	fmt.Println(" setlabel -address ADDRESS -label LABEL - Sets the label of a wallet address")
+	fmt.Println(" encryptwallet - Encrypts the wallet file with a passphrase")
+	fmt.Println(" walletpassphrase -timeout SECONDS - Unlocks the encrypted wallet for a while")
+	fmt.Println(" walletpassphrasechange - Changes the wallet passphrase")
+	fmt.Println(" walletlock - Locks the encrypted wallet again")
+	fmt.Println(" generate N -address ADDRESS - Mines N blocks immediately with the mempool's best paying transactions, sending the rewards and fees to address")
+	fmt.Println(" getmempool - Lists the transactions waiting for a block by package fee rate, with their unconfirmed ancestors and descendants")
+	fmt.Println(" bumpfee -txid TXID [-fee FEE] - Replaces a pending wallet transaction with one paying a higher fee")
+	fmt.Println(" issueasset -from FROM -name NAME [-supply AMOUNT] [-mintable] [-fee FEE] - Issues a new asset to FROM and prints its ID")
+	fmt.Println(" mintasset -from ISSUER -asset ID -amount AMOUNT [-fee FEE] - Mints more of a mintable asset")
+	fmt.Println(" sendasset -from FROM -to TO -asset ID -amount AMOUNT [-fee FEE] [-replaceable=false] - Queues a payment of an asset in the mempool")
+	fmt.Println(" getassetbalance [-address ADDRESS] - Lists the assets an address, or every wallet address, holds")
+	fmt.Println(" listassets - Lists the assets issued in the chain with their supply")
+	fmt.Println("  Asset outputs carry the dust threshold in coins, paid by the sender with the fee")
+	fmt.Println(" config show - Prints the effective configuration and where each value came from")
+}
+
+// defaultFee pays the default minimum relay fee for 10000 bytes.
+const defaultFee = blockchain.Amount(10000)
+
+// amountFlag defines a flag holding a decimal number of coins.
+func amountFlag(fs *flag.FlagSet, name string, value blockchain.Amount, usage string) *blockchain.Amount {
+	amount := &value
+	fs.Func(name, usage, func(s string) error {
+		parsed, err := blockchain.ParseAmount(s)
+		if err != nil {
+			return err
+		}
+		*amount = parsed
+		return nil
+	})
+	return amount
 }
 
 func (cli *CommandLine) validateArgs() {
-	if len(os.Args) < 2 {
+	if len(cli.args) < 1 {
 		cli.printUsage()
 		runtime.Goexit()
 	}
 }
 
-func (cli *CommandLine) listAddresses() {
-	wallets, _ := wallet.CreateWallets()
-	addresses := wallets.GetAllAddresses()
+func (cli *CommandLine) parseGlobalFlags() {
+	cli.config = DefaultConfig()
+
+	globalCmd := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	globalCmd.Usage = cli.printUsage
+	configFile := globalCmd.String("config", "", "The TOML config file to read")
+	flagValues := cli.config.RegisterFlags(globalCmd)
+
+	err := globalCmd.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path, required = os.Getenv(configEnv), true
+	}
+	if path == "" {
+		path, required = defaultConfigFile, false
+	}
+	err = cli.config.LoadFile(path, required)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = cli.config.LoadEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = cli.config.LoadFlags(globalCmd, flagValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cli.params, err = params.ForNetwork(cli.config.Network)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cli.config.DataDir != "" {
+		cli.params.SetDataDir(cli.config.DataDir)
+	} else {
+		cli.config.DataDir = cli.params.DataDir
+	}
+	cli.params.MinerThreads = cli.config.MinerThreads
+	cli.args = globalCmd.Args()
+}
+
+func (cli *CommandLine) listAddresses(sortBy, label, purpose string) {
+	wallets := cli.loadOrCreateWallets()
+
+	var addresses []string
+	for _, address := range cli.walletAddresses(wallets) {
+		info := wallets.Info(address)
+		if label != "" && !strings.Contains(strings.ToLower(info.Label), strings.ToLower(label)) {
+			continue
+		}
+		if purpose != "" && info.Purpose != purpose {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+
+	balances := make(map[string]blockchain.Amount)
+	if blockchain.DBExists(cli.params) {
+		chain := blockchain.ContinueBlockChain("", cli.params)
+		for _, address := range addresses {
+			balances[address] = coinBalance(chain.FindUTXO(cli.validateAddress(address).Hash))
+		}
+		chain.Database.Close()
+	}
+
+	sort.SliceStable(addresses, func(i, j int) bool {
+		a, b := wallets.Info(addresses[i]), wallets.Info(addresses[j])
+		switch sortBy {
+		case "label":
+			return a.Label < b.Label
+		case "created":
+			return a.Created < b.Created
+		case "balance":
+			return balances[addresses[i]] > balances[addresses[j]]
+		case "purpose":
+			return a.Purpose < b.Purpose
+		}
+		return addresses[i] < addresses[j]
+	})
+
 	for _, address := range addresses {
-		fmt.Println(address)
+		info := wallets.Info(address)
+		created := "unknown"
+		if info.Created != 0 {
+			created = time.Unix(info.Created, 0).Format(time.RFC3339)
+		}
+		fmt.Printf("%s %-10s %-25s %12s %q\n", address, info.Purpose, created, balances[address], info.Label)
+	}
+}
+
+func (cli *CommandLine) setLabel(address, label string) {
+	address = cli.walletAddress(address)
+	wallets := cli.loadWallets()
+	err := wallets.SetLabel(address, label)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cli.unlockWallets(wallets)
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
 	}
+	fmt.Printf("Label of %s set to %q\n", address, label)
 }
 
-func (cli *CommandLine) createWallet() {
-	wallets, _ := wallet.CreateWallets()
-	address := wallets.AddWallet()
-	wallets.SaveFile()
+func (cli *CommandLine) createWallet(label string, askPassphrase, bech32 bool, keyTypeName string) {
+	keyType, err := wallet.ParseKeyType(keyTypeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	wallets := cli.loadOrCreateWallets()
+	cli.unlockWallets(wallets)
+	if keyType != wallet.P256 {
+		address := wallets.AddWallet(keyType)
+		fmt.Printf("The HD seed only derives p256 keys, back up this %s key with exportkey\n", keyType)
+		cli.finishCreateWallet(wallets, address, label, bech32)
+		return
+	}
+	if !wallets.HasSeed() {
+		passphrase := ""
+		if askPassphrase {
+			passphrase = readPassphrase("Mnemonic passphrase: ")
+		}
+		mnemonic, err := wallets.NewSeed(passphrase)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Println("Write down these words, they restore every address of this wallet:")
+		fmt.Println(mnemonic)
+	}
+	address, err := wallets.NextAddress(wallet.ReceiveChain)
+	if err != nil {
+		log.Panic(err)
+	}
+	cli.finishCreateWallet(wallets, address, label, bech32)
+}
+
+func (cli *CommandLine) finishCreateWallet(wallets *wallet.Wallets, address, label string, bech32 bool) {
+	err := wallets.SetLabel(address, label)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
 
 	fmt.Printf("New address is: %s\n", address)
+	if bech32 {
+		encoded, err := cli.validateAddress(address).EncodeBech32(cli.params)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Printf("Bech32 form: %s\n", encoded)
+	}
+}
+
+func (cli *CommandLine) dumpMnemonic() {
+	wallets := cli.loadWallets()
+	cli.unlockWallets(wallets)
+	mnemonic, err := wallets.Mnemonic()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(mnemonic)
+}
+
+func (cli *CommandLine) restoreWallet(askPassphrase bool, gapLimit int) {
+	wallets := cli.loadOrCreateWallets()
+	if wallets.HasSeed() {
+		log.Fatalf("Wallet file %s already has an HD seed, restore into a new --datadir", cli.params.WalletPath())
+	}
+	cli.unlockWallets(wallets)
+
+	mnemonic := readPassphrase("Mnemonic: ")
+	passphrase := ""
+	if askPassphrase {
+		passphrase = readPassphrase("Mnemonic passphrase: ")
+	}
+	err := wallets.SetMnemonic(mnemonic, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !blockchain.DBExists(cli.params) {
+		err = wallets.SaveFile()
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Println("Seed restored, run discoveraddresses once a blockchain exists")
+		return
+	}
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	used := chain.UsedPublicKeyHashes()
+	found, err := wallets.Discover(func(publicKeyHash []byte) bool {
+		return used[hex.EncodeToString(publicKeyHash)]
+	}, gapLimit)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var total blockchain.Amount
+	for _, address := range found {
+		balance := coinBalance(chain.FindUTXO(wallet.PublicKeyHash(wallets.Wallets[address].PublicKey)))
+		total += balance
+		fmt.Printf("%s %s %s\n", wallets.Path(address), address, balance)
+	}
+	fmt.Printf("Restored %d used addresses holding %s\n", len(found), total)
+}
+
+func (cli *CommandLine) exportKey(address string) {
+	address = cli.walletAddress(address)
+	wallets := cli.loadWallets()
+	cli.unlockWallets(wallets)
+	key, err := wallets.ExportKey(address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(key)
+}
+
+func (cli *CommandLine) importKey() {
+	wallets := cli.loadOrCreateWallets()
+	cli.unlockWallets(wallets)
+	address, err := wallets.ImportKey(readPassphrase("Private key: "))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Imported address: %s\n", address)
+
+	if !blockchain.DBExists(cli.params) {
+		return
+	}
+	chain := blockchain.ContinueBlockChain(address, cli.params)
+	defer chain.Database.Close()
+
+	UTXOs := chain.FindUTXO(wallet.PublicKeyHash(wallets.Wallets[address].PublicKey))
+	fmt.Printf("Rescan found %d unspent outputs, balance of %s: %s\n", len(UTXOs), address, coinBalance(UTXOs))
+}
+
+func (cli *CommandLine) signMessage(address, message string) {
+	address = cli.walletAddress(address)
+	wallets := cli.loadWallets()
+	w, ok := wallets.Wallets[address]
+	if !ok {
+		log.Fatalf("Address %s has no private key in the wallet", address)
+	}
+	cli.unlockWallets(wallets)
+	w = wallets.Wallets[address]
+
+	signature, err := wallet.SignMessage(w, message)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(signature)
+}
+
+func (cli *CommandLine) verifyMessage(address, signature, message string) {
+	cli.validateAddress(address)
+	err := wallet.VerifyMessage(address, signature, message, cli.params)
+	if err != nil {
+		log.Fatalf("Verification failed: %s", err)
+	}
+	fmt.Println("Signature is valid")
+}
+
+func (cli *CommandLine) discoverAddresses(gapLimit int) {
+	wallets := cli.loadWallets()
+	if !wallets.HasSeed() {
+		log.Fatal(wallet.ErrNoSeed)
+	}
+	cli.unlockWallets(wallets)
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	used := chain.UsedPublicKeyHashes()
+	found, err := wallets.Discover(func(publicKeyHash []byte) bool {
+		return used[hex.EncodeToString(publicKeyHash)]
+	}, gapLimit)
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, address := range found {
+		fmt.Printf("%s %s\n", wallets.Path(address), address)
+	}
+	fmt.Printf("Found %d used addresses\n", len(found))
+}
+
+func (cli *CommandLine) validateAddress(address string) *wallet.Address {
+	addr, err := wallet.DecodeAddress(address, cli.params)
+	if err != nil {
+		log.Fatalf("Invalid address %s: %s", address, err)
+	}
+	return addr
+}
+
+func (cli *CommandLine) walletAddress(address string) string {
+	return cli.validateAddress(address).Encode(cli.params)
+}
+
+func (cli *CommandLine) convertAddress(address string) {
+	addr := cli.validateAddress(address)
+	fmt.Printf("Type: %s\n", addr.Type)
+	if addr.Type == wallet.PubKeyHashAddress {
+		fmt.Printf("Key type: %s\n", addr.Key)
+	}
+	fmt.Printf("Base58: %s\n", addr.Encode(cli.params))
+	bech32, err := addr.EncodeBech32(cli.params)
+	if err != nil {
+		fmt.Printf("Bech32: none (%s)\n", err)
+		return
+	}
+	fmt.Printf("Bech32: %s\n", bech32)
+}
+
+func (cli *CommandLine) loadWallets() *wallet.Wallets {
+	wallets, err := wallet.CreateWallets(cli.params)
+	if err != nil {
+		log.Fatalf("Could not load wallet file %s: %s", cli.params.WalletPath(), err)
+	}
+	return wallets
+}
+
+func (cli *CommandLine) loadOrCreateWallets() *wallet.Wallets {
+	wallets, err := wallet.CreateWallets(cli.params)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Could not load wallet file %s: %s", cli.params.WalletPath(), err)
+	}
+	return wallets
+}
+
+func (cli *CommandLine) encryptWallet() {
+	wallets := cli.loadWallets()
+	err := wallets.Encrypt(readNewPassphrase())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Println("Wallet encrypted, keep your passphrase safe")
+}
+
+// walletPassphrase hands the passphrase to a walletagent child process, which
+// keeps the unlocked key in memory until the timeout and answers later commands.
+func (cli *CommandLine) walletPassphrase(timeout int) {
+	wallets := cli.loadWallets()
+	if !wallets.IsEncrypted() {
+		log.Fatal(wallet.ErrNotEncrypted)
+	}
+	passphrase := readPassphrase("Wallet passphrase: ")
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Panic(err)
+	}
+	globalArgs := os.Args[1 : len(os.Args)-len(cli.args)]
+	args := append(append([]string{}, globalArgs...), "walletagent", "-timeout", strconv.Itoa(timeout))
+	agent := exec.Command(executable, args...)
+	input, err := agent.StdinPipe()
+	if err != nil {
+		log.Panic(err)
+	}
+	output, err := agent.StdoutPipe()
+	if err != nil {
+		log.Panic(err)
+	}
+	err = agent.Start()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Fprintln(input, passphrase)
+	input.Close()
+	reply, _ := bufio.NewReader(output).ReadString('\n')
+	reply = strings.TrimSpace(reply)
+	if reply != "ready" {
+		agent.Wait()
+		if reply == "" {
+			reply = "the wallet agent did not start"
+		}
+		log.Fatal(reply)
+	}
+	agent.Process.Release()
+	fmt.Printf("Wallet unlocked for %d seconds\n", timeout)
+}
+
+func (cli *CommandLine) walletAgent(timeout int) {
+	wallets, err := wallet.CreateWallets(cli.params)
+	if err == nil {
+		err = wallets.Unlock(readPassphrase(""))
+	}
+	if err == nil {
+		err = wallets.ServeUnlock(time.Duration(timeout)*time.Second, func() {
+			fmt.Println("ready")
+			os.Stdout.Close()
+		})
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (cli *CommandLine) walletPassphraseChange() {
+	wallets := cli.loadWallets()
+	oldPassphrase := readPassphrase("Current passphrase: ")
+	err := wallets.ChangePassphrase(oldPassphrase, readNewPassphrase())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	err = wallet.LockAgent(cli.params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wallet passphrase changed")
+}
+
+func (cli *CommandLine) walletLock() {
+	wallets := cli.loadWallets()
+	if !wallets.IsEncrypted() {
+		log.Fatal(wallet.ErrNotEncrypted)
+	}
+	err := wallet.LockAgent(cli.params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wallet locked")
 }
 
 func (cli *CommandLine) printChain() {
-	chain := blockchain.ContinueBlockChain("")
+	chain := blockchain.ContinueBlockChain("", cli.params)
 	defer chain.Database.Close()
 	iter := chain.Iterator()
 
 	for {
 		block := iter.Next()
 
+		fmt.Printf("Height: %d\n", block.Height)
+		if block.Timestamp != 0 {
+			fmt.Printf("Time: %s\n", time.Unix(block.Timestamp, 0).Format(time.RFC3339))
+		} else {
+			fmt.Println("Time: unknown")
+		}
 		fmt.Printf("Prev. hash: %x\n", block.PrevHash)
 		fmt.Printf("Hash: %x\n", block.Hash)
-		pow := blockchain.NewProof(block)
+		pow := blockchain.NewProof(block, cli.params.Difficulty)
 		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
 		for _, t := range block.Transactions {
 			fmt.Println(t)
@@ -71,51 +587,227 @@ func (cli *CommandLine) printChain() {
 }
 
 func (cli *CommandLine) createBlockChain(address string) {
-	if !wallet.ValidateAddress(address) {
-		log.Fatalf("Invalid address: %s", address)
-	}
-	chain := blockchain.InitBlockChain(address)
+	cli.validateAddress(address)
+	chain := blockchain.InitBlockChain(address, cli.params)
 	chain.Database.Close()
 	fmt.Println("Finished!")
 }
 
 func (cli *CommandLine) getBalance(address string) {
-	if !wallet.ValidateAddress(address) {
-		log.Fatalf("Invalid address: %s", address)
+	cli.validateAddress(address)
+
+	chain := blockchain.ContinueBlockChain(address, cli.params)
+	defer chain.Database.Close()
+
+	balance := coinBalance(chain.FindUTXO(cli.validateAddress(address).Hash))
+	fmt.Printf("Balance of %s: %s\n", address, balance)
+}
+
+func (cli *CommandLine) walletAddresses(wallets *wallet.Wallets) []string {
+	addresses := wallets.GetAllAddresses()
+	sort.Strings(addresses)
+	return append(addresses, wallets.GetWatchOnlyAddresses()...)
+}
+
+func (cli *CommandLine) getWalletBalance() {
+	wallets := cli.loadWallets()
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	var total blockchain.Amount
+	for _, address := range cli.walletAddresses(wallets) {
+		balance := coinBalance(chain.FindUTXO(cli.validateAddress(address).Hash))
+		total += balance
+
+		marker := ""
+		if wallets.IsWatchOnly(address) {
+			marker = " (watch-only)"
+		}
+		fmt.Printf("Balance of %s: %s%s\n", address, balance, marker)
+	}
+	fmt.Printf("Total: %s\n", total)
+}
+
+func (cli *CommandLine) history(address string) {
+	var addresses []string
+	if address != "" {
+		cli.validateAddress(address)
+		addresses = []string{address}
+	} else {
+		addresses = cli.walletAddresses(cli.loadWallets())
 	}
 
-	chain := blockchain.ContinueBlockChain(address)
+	chain := blockchain.ContinueBlockChain(address, cli.params)
 	defer chain.Database.Close()
 
-	balance := 0
-	publicKey := wallet.Base58Decode([]byte(address))
-	checksum := len(publicKey) - 4
-	publicKey = publicKey[1:checksum]
-	UTXOs := chain.FindUTXO(publicKey)
-	for _, UTXO := range UTXOs {
-		balance += UTXO.Value
+	for _, address := range addresses {
+		fmt.Printf("History of %s:\n", address)
+		for _, entry := range chain.FindHistory(cli.validateAddress(address).Hash) {
+			fmt.Printf(" %d %s %x received %s sent %s\n", entry.Height,
+				time.Unix(entry.Timestamp, 0).Format(time.RFC3339), entry.ID, entry.Received, entry.Sent)
+		}
+	}
+}
+
+func (cli *CommandLine) importAddress(address, publicKeyHex string) {
+	var publicKey []byte
+	if publicKeyHex != "" {
+		var err error
+		publicKey, err = hex.DecodeString(publicKeyHex)
+		if err != nil || len(publicKey) == 0 {
+			log.Fatalf("Invalid public key: %s", publicKeyHex)
+		}
+		if address == "" {
+			address = string(wallet.Wallet{PublicKey: publicKey}.Address(cli.params.AddressVersion))
+		}
 	}
+	address = cli.walletAddress(address)
 
-	fmt.Printf("Balance of %s: %d\n", address, balance)
+	wallets := cli.loadOrCreateWallets()
+	err := wallets.AddWatchOnly(address, publicKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Watching address: %s\n", address)
 }
 
-func (cli *CommandLine) send(from, to string, amount int) {
-	if !wallet.ValidateAddress(from) {
-		log.Fatalf("Invalid address: %s", from)
+func (cli *CommandLine) send(from, to string, amount, fee blockchain.Amount, replaceable bool, hashTypeName string, mine bool) {
+	hashType, err := blockchain.ParseSigHashType(hashTypeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	from = cli.walletAddress(from)
+	if cli.validateAddress(to).Type != wallet.PubKeyHashAddress {
+		log.Fatalf("Cannot send to %s: script-hash outputs are not spendable on this chain yet", to)
 	}
-	if !wallet.ValidateAddress(to) {
-		log.Fatalf("Invalid address: %s", to)
+
+	wallets := cli.loadWallets()
+	w, ok := wallets.Wallets[from]
+	if !ok && !wallets.IsWatchOnly(from) {
+		log.Fatal("Wallet not found")
+	}
+
+	chain := blockchain.ContinueBlockChain(from, cli.params)
+	defer chain.Database.Close()
+
+	if !ok {
+		publicKey := wallets.WatchOnlyPublicKey(from)
+		if publicKey == nil {
+			log.Fatalf("Address %s is watch-only without a public key, import it with -pubkey to build transactions", from)
+		}
+		tx := blockchain.NewTransaction(from, publicKey, to, amount, fee, replaceable, nil, chain)
+		fmt.Printf("Unsigned transaction: %x\n", tx.Serialize())
+		log.Fatalf("Address %s is watch-only, refusing to sign", from)
+	}
+
+	tx := blockchain.NewTransaction(from, w.PublicKey, to, amount, fee, replaceable, cli.changeAddress(wallets), chain)
+	cli.unlockWallets(wallets)
+	chain.SignTransaction(tx, wallets.GetWallet(from), hashType)
+	cli.submitTransaction(chain, tx)
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if mine {
+		block := chain.Generate(from, 1)[0]
+		fmt.Printf("Mined block %d %x\n", block.Height, block.Hash)
+	}
+}
+
+// changeAddress hands out fresh change addresses from a seeded wallet, other
+// wallets keep the change on the sending address.
+func (cli *CommandLine) changeAddress(wallets *wallet.Wallets) func() string {
+	if !wallets.HasSeed() {
+		return nil
 	}
+	return func() string {
+		cli.unlockWallets(wallets)
+		address, err := wallets.NextAddress(wallet.ChangeChain)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Printf("Change goes to %s\n", address)
+		return address
+	}
+}
+
+func decodeRawTransaction(txHex string) *blockchain.Transaction {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		log.Fatalf("Invalid transaction hex: %s", err)
+	}
+	tx := blockchain.DeserializeTransaction(raw)
+	return &tx
+}
+
+// describeSpend shows a signer what it is about to authorise. The totals come
+// from the spent outputs in the local chain and, from version 1, the
+// signatures commit to them, so a transaction that lies about its inputs
+// cannot be signed into a valid one.
+func describeSpend(chain *blockchain.BlockChain, tx *blockchain.Transaction) {
+	in, out := chain.InputValue(tx), tx.OutputValue()
+	fmt.Printf("Spending %s from %d inputs\n", in, len(tx.Inputs))
+	fmt.Printf("Paying %s to %d outputs, fee %s\n", out, len(tx.Outputs), in-out)
+	if tx.Version < blockchain.TxVersion {
+		fmt.Printf("Warning: version %d transaction, signatures do not commit to the input values\n", tx.Version)
+	}
+}
+
+func (cli *CommandLine) signRawTransaction(txHex, address, hashTypeName string) {
+	hashType, err := blockchain.ParseSigHashType(hashTypeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	address = cli.walletAddress(address)
+	tx := decodeRawTransaction(txHex)
+
+	wallets := cli.loadWallets()
+	if _, ok := wallets.Wallets[address]; !ok {
+		log.Fatal("Wallet not found")
+	}
+	cli.unlockWallets(wallets)
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	fmt.Println(tx)
+	describeSpend(chain, tx)
+	signed := chain.SignTransaction(tx, wallets.GetWallet(address), hashType)
+	if signed == 0 {
+		log.Fatalf("No input of this transaction spends from %s", address)
+	}
+	fmt.Printf("Signed %d inputs with %s\n", signed, hashType)
+	fmt.Printf("Transaction: %x\n", tx.Serialize())
+}
+
+func (cli *CommandLine) sendRawTransaction(txHex string) {
+	tx := decodeRawTransaction(txHex)
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	cli.submitTransaction(chain, tx)
+}
+
+func (cli *CommandLine) generate(address string, count int) {
+	cli.validateAddress(address)
 
-	chain := blockchain.ContinueBlockChain(from)
+	chain := blockchain.ContinueBlockChain(address, cli.params)
 	defer chain.Database.Close()
 
-	tx := blockchain.NewTransaction(from, to, amount, chain)
-	chain.AddBlock([]*blockchain.Transaction{tx})
-	fmt.Println("Success!")
+	blocks := chain.Generate(address, count)
+	for _, block := range blocks {
+		fmt.Printf("%d %x\n", block.Height, block.Hash)
+	}
 }
 
 func (cli *CommandLine) Run() {
+	cli.parseGlobalFlags()
 	cli.validateArgs()
 
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
@@ -124,41 +816,313 @@ func (cli *CommandLine) Run() {
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	configCmd := flag.NewFlagSet("config", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletPassphraseChangeCmd := flag.NewFlagSet("walletpassphrasechange", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	walletAgentCmd := flag.NewFlagSet("walletagent", flag.ExitOnError)
+	discoverAddressesCmd := flag.NewFlagSet("discoveraddresses", flag.ExitOnError)
+	dumpMnemonicCmd := flag.NewFlagSet("dumpmnemonic", flag.ExitOnError)
+	exportKeyCmd := flag.NewFlagSet("exportkey", flag.ExitOnError)
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+	setLabelCmd := flag.NewFlagSet("setlabel", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	convertAddressCmd := flag.NewFlagSet("convertaddress", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	importKeyCmd := flag.NewFlagSet("importkey", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	getPubKeyCmd := flag.NewFlagSet("getpubkey", flag.ExitOnError)
+	signRawTransactionCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	getMempoolCmd := flag.NewFlagSet("getmempool", flag.ExitOnError)
+	bumpFeeCmd := flag.NewFlagSet("bumpfee", flag.ExitOnError)
+	sendRawTransactionCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	musigAddressCmd := flag.NewFlagSet("musigaddress", flag.ExitOnError)
+	musigInitCmd := flag.NewFlagSet("musiginit", flag.ExitOnError)
+	musigNonceCmd := flag.NewFlagSet("musignonce", flag.ExitOnError)
+	musigSignCmd := flag.NewFlagSet("musigsign", flag.ExitOnError)
+	musigFinishCmd := flag.NewFlagSet("musigfinish", flag.ExitOnError)
+	issueAssetCmd := flag.NewFlagSet("issueasset", flag.ExitOnError)
+	mintAssetCmd := flag.NewFlagSet("mintasset", flag.ExitOnError)
+	sendAssetCmd := flag.NewFlagSet("sendasset", flag.ExitOnError)
+	getAssetBalanceCmd := flag.NewFlagSet("getassetbalance", flag.ExitOnError)
+	listAssetsCmd := flag.NewFlagSet("listassets", flag.ExitOnError)
 
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
 	sendFrom := sendCmd.String("from", "", "Source wallet address")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
-	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendAmount := amountFlag(sendCmd, "amount", 0, "Amount to send, in coins with up to 8 decimals")
+	sendFee := amountFlag(sendCmd, "fee", defaultFee, "Fee paid to the miner on top of the amount (default "+defaultFee.String()+")")
+	sendReplaceable := sendCmd.Bool("replaceable", true, "Signal that the payment may be replaced with one paying a higher fee")
+	sendSigHash := sendCmd.String("sighash", "all", "What the signatures commit to: all, none or single, optionally |anyonecanpay")
+	sendMine := sendCmd.Bool("mine", false, "Mine a block with the payment right away")
+	generateAddress := generateCmd.String("address", "", "The address to send the block rewards to")
+	generateCount := 0
+	discoverGap := discoverAddressesCmd.Int("gap", wallet.DefaultGapLimit, "Number of consecutive unused addresses before discovery stops")
+	createWalletLabel := createWalletCmd.String("label", "", "A label for the new address")
+	listSort := listAddressesCmd.String("sort", "address", "Sort by address, label, created, purpose or balance")
+	listLabel := listAddressesCmd.String("label", "", "Only list addresses whose label contains this text")
+	listPurpose := listAddressesCmd.String("purpose", "", "Only list receive, change, imported or watch-only addresses")
+	setLabelAddress := setLabelCmd.String("address", "", "The address to label")
+	setLabelLabel := setLabelCmd.String("label", "", "The new label")
+	signMessageAddress := signMessageCmd.String("address", "", "The address whose key signs the message")
+	signMessageMessage := signMessageCmd.String("message", "", "The message to sign")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "The address that signed the message")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "The signature produced by signmessage")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "The signed message")
+	createWalletBech32 := createWalletCmd.Bool("bech32", false, "Also print the Bech32 form of the new address")
+	createWalletType := createWalletCmd.String("type", "p256", "The key type: p256, secp256k1, ed25519 or schnorr")
+	convertAddressAddress := convertAddressCmd.String("address", "", "The address to convert")
+	createWalletPassphrase := createWalletCmd.Bool("passphrase", false, "Ask for a passphrase protecting a newly created mnemonic")
+	restorePassphrase := restoreWalletCmd.Bool("passphrase", false, "Ask for the passphrase protecting the mnemonic")
+	restoreGap := restoreWalletCmd.Int("gap", wallet.DefaultGapLimit, "Number of consecutive unused addresses before discovery stops")
+	exportKeyAddress := exportKeyCmd.String("address", "", "The address whose private key is exported")
+	historyAddress := historyCmd.String("address", "", "The address to list transactions for")
+	importAddressAddress := importAddressCmd.String("address", "", "The address to watch")
+	importAddressPubKey := importAddressCmd.String("pubkey", "", "The hex public key to watch, needed to build unsigned transactions")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 60, "Seconds to keep the wallet unlocked")
+	walletAgentTimeout := walletAgentCmd.Int("timeout", 60, "Seconds to keep the wallet unlocked")
+	signRawTransactionTx := signRawTransactionCmd.String("tx", "", "The hex encoded transaction")
+	signRawTransactionAddress := signRawTransactionCmd.String("address", "", "The address whose inputs are signed")
+	signRawTransactionSigHash := signRawTransactionCmd.String("sighash", "all", "What the signatures commit to: all, none or single, optionally |anyonecanpay")
+	bumpFeeTxID := bumpFeeCmd.String("txid", "", "The pending transaction to replace")
+	bumpFeeFee := amountFlag(bumpFeeCmd, "fee", 0, "The new total fee, by default the current fee plus the minimum relay fee")
+	sendRawTransactionTx := sendRawTransactionCmd.String("tx", "", "The hex encoded signed transaction")
+	getPubKeyAddress := getPubKeyCmd.String("address", "", "The wallet address whose public key is printed")
+	musigAddressKeys := musigAddressCmd.String("pubkeys", "", "Comma separated hex schnorr public keys")
+	musigInitKeys := musigInitCmd.String("pubkeys", "", "Comma separated hex schnorr public keys")
+	musigInitTo := musigInitCmd.String("to", "", "Destination wallet address")
+	musigInitAmount := amountFlag(musigInitCmd, "amount", 0, "Amount to send, in coins with up to 8 decimals")
+	musigInitFee := amountFlag(musigInitCmd, "fee", defaultFee, "Fee paid to the miner on top of the amount (default "+defaultFee.String()+")")
+	musigInitSession := musigInitCmd.String("session", "", "The session file to create")
+	musigNonceSession := musigNonceCmd.String("session", "", "The session file")
+	musigNonceAddress := musigNonceCmd.String("address", "", "This signer's schnorr address")
+	musigSignSession := musigSignCmd.String("session", "", "The session file")
+	musigSignAddress := musigSignCmd.String("address", "", "This signer's schnorr address")
+	musigFinishSession := musigFinishCmd.String("session", "", "The session file")
+	issueAssetFrom := issueAssetCmd.String("from", "", "The wallet address that pays for and receives the issue")
+	issueAssetName := issueAssetCmd.String("name", "", "The name of the asset")
+	issueAssetSupply := amountFlag(issueAssetCmd, "supply", 0, "Amount issued now, with up to 8 decimals")
+	issueAssetMintable := issueAssetCmd.Bool("mintable", false, "Let the issuing address mint more later")
+	issueAssetFee := amountFlag(issueAssetCmd, "fee", defaultFee, "Fee paid to the miner (default "+defaultFee.String()+")")
+	mintAssetFrom := mintAssetCmd.String("from", "", "The address that issued the asset")
+	mintAssetAsset := mintAssetCmd.String("asset", "", "The hex asset ID")
+	mintAssetAmount := amountFlag(mintAssetCmd, "amount", 0, "Amount to mint, with up to 8 decimals")
+	mintAssetFee := amountFlag(mintAssetCmd, "fee", defaultFee, "Fee paid to the miner (default "+defaultFee.String()+")")
+	sendAssetFrom := sendAssetCmd.String("from", "", "Source wallet address")
+	sendAssetTo := sendAssetCmd.String("to", "", "Destination wallet address")
+	sendAssetAsset := sendAssetCmd.String("asset", "", "The hex asset ID")
+	sendAssetAmount := amountFlag(sendAssetCmd, "amount", 0, "Amount of the asset to send, with up to 8 decimals")
+	sendAssetFee := amountFlag(sendAssetCmd, "fee", defaultFee, "Fee paid to the miner (default "+defaultFee.String()+")")
+	sendAssetReplaceable := sendAssetCmd.Bool("replaceable", true, "Signal that the payment may be replaced with one paying a higher fee")
+	getAssetBalanceAddress := getAssetBalanceCmd.String("address", "", "The address to list assets for")
 
-	switch os.Args[1] {
+	switch cli.args[0] {
 	case "getbalance":
-		err := getBalanceCmd.Parse(os.Args[2:])
+		err := getBalanceCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listaddresses":
-		err := listAddressesCmd.Parse(os.Args[2:])
+		err := listAddressesCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createwallet":
-		err := createWalletCmd.Parse(os.Args[2:])
+		err := createWalletCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "send":
-		err := sendCmd.Parse(os.Args[2:])
+		err := sendCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		err := generateCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+		if generateCmd.NArg() > 0 {
+			generateCount, err = strconv.Atoi(generateCmd.Arg(0))
+			if err != nil {
+				log.Fatalf("Invalid block count: %s", generateCmd.Arg(0))
+			}
+			err = generateCmd.Parse(generateCmd.Args()[1:])
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrasechange":
+		err := walletPassphraseChangeCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletagent":
+		err := walletAgentCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "discoveraddresses":
+		err := discoverAddressesCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpmnemonic":
+		err := dumpMnemonicCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "exportkey":
+		err := exportKeyCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importkey":
+		err := importKeyCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "history":
+		err := historyCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "setlabel":
+		err := setLabelCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmessage":
+		err := signMessageCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTransactionCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getmempool":
+		err := getMempoolCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "bumpfee":
+		err := bumpFeeCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawTransactionCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getpubkey":
+		err := getPubKeyCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "musigaddress":
+		err := musigAddressCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "musiginit":
+		err := musigInitCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "musignonce":
+		err := musigNonceCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "musigsign":
+		err := musigSignCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "musigfinish":
+		err := musigFinishCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "convertaddress":
+		err := convertAddressCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "config":
+		err := configCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "issueasset":
+		err := issueAssetCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "mintasset":
+		err := mintAssetCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendasset":
+		err := sendAssetCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "getassetbalance":
+		err := getAssetBalanceCmd.Parse(cli.args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listassets":
+		err := listAssetsCmd.Parse(cli.args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
@@ -169,10 +1133,22 @@ func (cli *CommandLine) Run() {
 
 	if getBalanceCmd.Parsed() {
 		if *getBalanceAddress == "" {
-			getBalanceCmd.Usage()
+			cli.getWalletBalance()
+		} else {
+			cli.getBalance(*getBalanceAddress)
+		}
+	}
+
+	if historyCmd.Parsed() {
+		cli.history(*historyAddress)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" && *importAddressPubKey == "" {
+			importAddressCmd.Usage()
 			runtime.Goexit()
 		}
-		cli.getBalance(*getBalanceAddress)
+		cli.importAddress(*importAddressAddress, *importAddressPubKey)
 	}
 
 	if createBlockchainCmd.Parsed() {
@@ -188,10 +1164,10 @@ func (cli *CommandLine) Run() {
 	}
 
 	if createWalletCmd.Parsed() {
-		cli.createWallet()
+		cli.createWallet(*createWalletLabel, *createWalletPassphrase, *createWalletBech32, *createWalletType)
 	}
 	if listAddressesCmd.Parsed() {
-		cli.listAddresses()
+		cli.listAddresses(*listSort, *listLabel, *listPurpose)
 	}
 
 	if sendCmd.Parsed() {
@@ -200,6 +1176,221 @@ func (cli *CommandLine) Run() {
 			runtime.Goexit()
 		}
 
-		cli.send(*sendFrom, *sendTo, *sendAmount)
+		cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, *sendReplaceable, *sendSigHash, *sendMine)
+	}
+
+	if generateCmd.Parsed() {
+		if *generateAddress == "" {
+			*generateAddress = cli.config.MiningAddress
+		}
+		if *generateAddress == "" || generateCount <= 0 {
+			generateCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.generate(*generateAddress, generateCount)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		cli.encryptWallet()
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphraseTimeout <= 0 {
+			walletPassphraseCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.walletPassphrase(*walletPassphraseTimeout)
+	}
+
+	if walletPassphraseChangeCmd.Parsed() {
+		cli.walletPassphraseChange()
+	}
+
+	if walletLockCmd.Parsed() {
+		cli.walletLock()
+	}
+
+	if walletAgentCmd.Parsed() {
+		cli.walletAgent(*walletAgentTimeout)
+	}
+
+	if discoverAddressesCmd.Parsed() {
+		if *discoverGap <= 0 {
+			discoverAddressesCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.discoverAddresses(*discoverGap)
+	}
+
+	if dumpMnemonicCmd.Parsed() {
+		cli.dumpMnemonic()
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreGap <= 0 {
+			restoreWalletCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.restoreWallet(*restorePassphrase, *restoreGap)
+	}
+
+	if exportKeyCmd.Parsed() {
+		if *exportKeyAddress == "" {
+			exportKeyCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.exportKey(*exportKeyAddress)
+	}
+
+	if importKeyCmd.Parsed() {
+		cli.importKey()
+	}
+
+	if setLabelCmd.Parsed() {
+		if *setLabelAddress == "" {
+			setLabelCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.setLabel(*setLabelAddress, *setLabelLabel)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" {
+			signMessageCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.signMessage(*signMessageAddress, *signMessageMessage)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.verifyMessage(*verifyMessageAddress, *verifyMessageSignature, *verifyMessageMessage)
+	}
+
+	if signRawTransactionCmd.Parsed() {
+		if *signRawTransactionTx == "" || *signRawTransactionAddress == "" {
+			signRawTransactionCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.signRawTransaction(*signRawTransactionTx, *signRawTransactionAddress, *signRawTransactionSigHash)
+	}
+
+	if getMempoolCmd.Parsed() {
+		cli.getMempool()
+	}
+
+	if bumpFeeCmd.Parsed() {
+		if *bumpFeeTxID == "" {
+			bumpFeeCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.bumpFee(*bumpFeeTxID, *bumpFeeFee)
+	}
+
+	if sendRawTransactionCmd.Parsed() {
+		if *sendRawTransactionTx == "" {
+			sendRawTransactionCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.sendRawTransaction(*sendRawTransactionTx)
+	}
+
+	if getPubKeyCmd.Parsed() {
+		if *getPubKeyAddress == "" {
+			getPubKeyCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.getPubKey(*getPubKeyAddress)
+	}
+
+	if musigAddressCmd.Parsed() {
+		if *musigAddressKeys == "" {
+			musigAddressCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.createMuSigAddress(*musigAddressKeys)
+	}
+
+	if musigInitCmd.Parsed() {
+		if *musigInitKeys == "" || *musigInitTo == "" || *musigInitAmount <= 0 || *musigInitSession == "" {
+			musigInitCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.musigInit(*musigInitKeys, *musigInitTo, *musigInitAmount, *musigInitFee, *musigInitSession)
+	}
+
+	if musigNonceCmd.Parsed() {
+		if *musigNonceSession == "" || *musigNonceAddress == "" {
+			musigNonceCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.musigNonce(*musigNonceSession, *musigNonceAddress)
+	}
+
+	if musigSignCmd.Parsed() {
+		if *musigSignSession == "" || *musigSignAddress == "" {
+			musigSignCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.musigSign(*musigSignSession, *musigSignAddress)
+	}
+
+	if musigFinishCmd.Parsed() {
+		if *musigFinishSession == "" {
+			musigFinishCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.musigFinish(*musigFinishSession)
+	}
+
+	if issueAssetCmd.Parsed() {
+		if *issueAssetFrom == "" || *issueAssetName == "" || (*issueAssetSupply <= 0 && !*issueAssetMintable) {
+			issueAssetCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.issueAsset(*issueAssetFrom, *issueAssetName, *issueAssetSupply, *issueAssetFee, *issueAssetMintable)
+	}
+
+	if mintAssetCmd.Parsed() {
+		if *mintAssetFrom == "" || *mintAssetAsset == "" || *mintAssetAmount <= 0 {
+			mintAssetCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.mintAsset(*mintAssetFrom, *mintAssetAsset, *mintAssetAmount, *mintAssetFee)
+	}
+
+	if sendAssetCmd.Parsed() {
+		if *sendAssetFrom == "" || *sendAssetTo == "" || *sendAssetAsset == "" || *sendAssetAmount <= 0 {
+			sendAssetCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.sendAsset(*sendAssetFrom, *sendAssetTo, *sendAssetAsset, *sendAssetAmount, *sendAssetFee, *sendAssetReplaceable)
+	}
+
+	if getAssetBalanceCmd.Parsed() {
+		cli.getAssetBalance(*getAssetBalanceAddress)
+	}
+
+	if listAssetsCmd.Parsed() {
+		cli.listAssets()
+	}
+
+	if convertAddressCmd.Parsed() {
+		if *convertAddressAddress == "" {
+			convertAddressCmd.Usage()
+			runtime.Goexit()
+		}
+		cli.convertAddress(*convertAddressAddress)
+	}
+
+	if configCmd.Parsed() {
+		if configCmd.Arg(0) != "show" {
+			cli.printUsage()
+			runtime.Goexit()
+		}
+		cli.config.Show()
 	}
 }
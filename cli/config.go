@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang-blockchain/blockchain"
+	"golang-blockchain/wallet"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	defaultConfigFile = "./blockchain.toml"
+	configEnv         = "BLOCKCHAIN_CONFIG"
+	envPrefix         = "BLOCKCHAIN_"
+)
+
+type Config struct {
+	DataDir       string
+	Network       string
+	MiningAddress string
+	MinerThreads  int
+	DustThreshold blockchain.Amount
+	MaxInputs     int
+	OutputTypes   string
+	MinRelayFee   blockchain.Amount
+
+	file    string
+	sources map[string]string
+}
+
+type setting struct {
+	key   string
+	usage string
+	get   func(c *Config) string
+	set   func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{
+		key:   "datadir",
+		usage: "The directory holding the blocks database and wallet file",
+		get:   func(c *Config) string { return c.DataDir },
+		set:   func(c *Config, v string) error { c.DataDir = v; return nil },
+	},
+	{
+		key:   "network",
+		usage: "The network to use: mainnet, testnet or regtest",
+		get:   func(c *Config) string { return c.Network },
+		set:   func(c *Config, v string) error { c.Network = v; return nil },
+	},
+	{
+		key:   "miningaddress",
+		usage: "The address that receives mining rewards",
+		get:   func(c *Config) string { return c.MiningAddress },
+		set:   func(c *Config, v string) error { c.MiningAddress = v; return nil },
+	},
+	{
+		key:   "minerthreads",
+		usage: "The number of threads used for proof of work",
+		get:   func(c *Config) string { return strconv.Itoa(c.MinerThreads) },
+		set: func(c *Config, v string) error {
+			threads, err := strconv.Atoi(v)
+			if err != nil || threads < 1 {
+				return fmt.Errorf("minerthreads must be a positive integer, got %q", v)
+			}
+			c.MinerThreads = threads
+			return nil
+		},
+	},
+	{
+		key:   "dustthreshold",
+		usage: "Relay policy: the smallest output value relayed, in coins",
+		get:   func(c *Config) string { return c.DustThreshold.String() },
+		set: func(c *Config, v string) error {
+			value, err := blockchain.ParseAmount(v)
+			if err != nil {
+				return fmt.Errorf("dustthreshold: %s", err)
+			}
+			c.DustThreshold = value
+			return nil
+		},
+	},
+	{
+		key:   "maxinputs",
+		usage: "Relay policy: the most inputs a relayed transaction may have",
+		get:   func(c *Config) string { return strconv.Itoa(c.MaxInputs) },
+		set: func(c *Config, v string) error {
+			value, err := strconv.Atoi(v)
+			if err != nil || value < 1 {
+				return fmt.Errorf("maxinputs must be a positive integer, got %q", v)
+			}
+			c.MaxInputs = value
+			return nil
+		},
+	},
+	{
+		key:   "outputtypes",
+		usage: "Relay policy: the comma separated key types outputs may be locked to",
+		get:   func(c *Config) string { return c.OutputTypes },
+		set: func(c *Config, v string) error {
+			if _, err := parseKeyTypes(v); err != nil {
+				return err
+			}
+			c.OutputTypes = v
+			return nil
+		},
+	},
+	{
+		key:   "minrelayfee",
+		usage: "Relay policy: the fee per 1000 bytes a transaction must pay, in coins",
+		get:   func(c *Config) string { return c.MinRelayFee.String() },
+		set: func(c *Config, v string) error {
+			value, err := blockchain.ParseAmount(v)
+			if err != nil {
+				return fmt.Errorf("minrelayfee: %s", err)
+			}
+			c.MinRelayFee = value
+			return nil
+		},
+	},
+}
+
+func DefaultConfig() *Config {
+	policy := blockchain.DefaultPolicy()
+	var outputTypes []string
+	for _, keyType := range policy.OutputTypes {
+		outputTypes = append(outputTypes, keyType.String())
+	}
+	return &Config{
+		Network:       "mainnet",
+		MinerThreads:  1,
+		DustThreshold: policy.DustThreshold,
+		MaxInputs:     policy.MaxInputs,
+		OutputTypes:   strings.Join(outputTypes, ","),
+		MinRelayFee:   policy.MinRelayFee,
+		sources:       make(map[string]string),
+	}
+}
+
+func parseKeyTypes(list string) ([]wallet.KeyType, error) {
+	var keyTypes []wallet.KeyType
+	for _, name := range strings.Split(list, ",") {
+		keyType, err := wallet.ParseKeyType(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		keyTypes = append(keyTypes, keyType)
+	}
+	return keyTypes, nil
+}
+
+// Policy is the relay policy the config describes.
+func (c *Config) Policy() *blockchain.Policy {
+	outputTypes, err := parseKeyTypes(c.OutputTypes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &blockchain.Policy{
+		DustThreshold: c.DustThreshold,
+		MaxInputs:     c.MaxInputs,
+		OutputTypes:   outputTypes,
+		MinRelayFee:   c.MinRelayFee,
+	}
+}
+
+func (c *Config) setFrom(s setting, value, source string) error {
+	if err := s.set(c, value); err != nil {
+		return fmt.Errorf("%s: %s", source, err)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+func (c *Config) LoadFile(path string, required bool) error {
+	var raw map[string]interface{}
+	md, err := toml.DecodeFile(path, &raw)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.file = path
+
+	for _, key := range md.Keys() {
+		if lookupSetting(key.String()) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, key.String())
+		}
+	}
+	for _, s := range settings {
+		if !md.IsDefined(s.key) {
+			continue
+		}
+		err = c.setFrom(s, tomlValue(raw[s.key]), "file "+path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tomlValue formats a value the way it would be given on the command line.
+// Floats are written out in full, fmt would print 0.00001 as 1e-05.
+func tomlValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func (c *Config) LoadEnv() error {
+	for _, s := range settings {
+		name := envName(s.key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		err := c.setFrom(s, value, "env "+name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) RegisterFlags(fs *flag.FlagSet) map[string]*string {
+	values := make(map[string]*string)
+	for _, s := range settings {
+		values[s.key] = fs.String(s.key, "", s.usage)
+	}
+	return values
+}
+
+func (c *Config) LoadFlags(fs *flag.FlagSet, values map[string]*string) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		s := lookupSetting(f.Name)
+		if s == nil || err != nil {
+			return
+		}
+		err = c.setFrom(*s, *values[f.Name], "flag --"+f.Name)
+	})
+	return err
+}
+
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+func (c *Config) Show() {
+	if c.file != "" {
+		fmt.Printf("Config file: %s\n", c.file)
+	} else {
+		fmt.Println("Config file: none")
+	}
+	for _, s := range settings {
+		fmt.Printf(" %-14s = %-40q (%s)\n", s.key, s.get(c), c.Source(s.key))
+	}
+}
+
+func lookupSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-blockchain/blockchain"
+	"golang-blockchain/wallet"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blockchain.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadConfig merges the layers the way parseGlobalFlags does.
+func loadConfig(t *testing.T, path string, args ...string) (*Config, error) {
+	t.Helper()
+	c := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	values := c.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadFile(path, path != ""); err != nil {
+		return nil, err
+	}
+	if err := c.LoadEnv(); err != nil {
+		return nil, err
+	}
+	return c, c.LoadFlags(fs, values)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+network = "testnet"
+miningaddress = "from-file"
+minerthreads = 2
+datadir = "file-dir"
+`)
+	t.Setenv("BLOCKCHAIN_MINERTHREADS", "3")
+	t.Setenv("BLOCKCHAIN_DATADIR", "env-dir")
+
+	c, err := loadConfig(t, path, "--datadir", "flag-dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, value, source string
+	}{
+		{"network", "testnet", "file " + path},
+		{"miningaddress", "from-file", "file " + path},
+		{"minerthreads", "3", "env BLOCKCHAIN_MINERTHREADS"},
+		{"datadir", "flag-dir", "flag --datadir"},
+	}
+	for _, test := range tests {
+		if value := lookupSetting(test.key).get(c); value != test.value {
+			t.Errorf("%s = %q, want %q", test.key, value, test.value)
+		}
+		if source := c.Source(test.key); source != test.source {
+			t.Errorf("%s comes from %q, want %q", test.key, source, test.source)
+		}
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	c, err := loadConfig(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Network != "mainnet" || c.MinerThreads != 1 {
+		t.Fatalf("defaults are network %q with %d miner threads", c.Network, c.MinerThreads)
+	}
+	for _, s := range settings {
+		if source := c.Source(s.key); source != "default" {
+			t.Errorf("%s comes from %q without a file, env or flag", s.key, source)
+		}
+	}
+
+	// The default file is optional, a file named explicitly is not.
+	if err := DefaultConfig().LoadFile(filepath.Join(t.TempDir(), "missing.toml"), false); err != nil {
+		t.Fatalf("a missing optional config file: %v", err)
+	}
+	if err := DefaultConfig().LoadFile(filepath.Join(t.TempDir(), "missing.toml"), true); err == nil {
+		t.Fatal("a missing config file given explicitly was ignored")
+	}
+}
+
+func TestConfigRejectsBadSettings(t *testing.T) {
+	if _, err := loadConfig(t, writeConfig(t, `rpclisten = "127.0.0.1:8332"`)); err == nil || !strings.Contains(err.Error(), "unknown setting") {
+		t.Errorf("an unknown setting in the file: got %v", err)
+	}
+	if _, err := loadConfig(t, writeConfig(t, `minerthreads = 0`)); err == nil || !strings.Contains(err.Error(), "minerthreads") {
+		t.Errorf("zero miner threads in the file: got %v", err)
+	}
+
+	t.Setenv("BLOCKCHAIN_MINERTHREADS", "many")
+	_, err := loadConfig(t, "")
+	if err == nil || !strings.Contains(err.Error(), "env BLOCKCHAIN_MINERTHREADS") {
+		t.Errorf("an invalid env value: got %v, want an error naming the variable", err)
+	}
+}
+
+func TestConfigPolicy(t *testing.T) {
+	c, err := loadConfig(t, writeConfig(t, `
+dustthreshold = 0.00001
+outputtypes = "secp256k1, schnorr"
+`), "--minrelayfee", "0.0002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := c.Policy()
+	if policy.DustThreshold != 1000 || policy.MinRelayFee != 20000 || policy.MaxInputs != blockchain.DefaultPolicy().MaxInputs {
+		t.Fatalf("policy is %+v", policy)
+	}
+	if len(policy.OutputTypes) != 2 || policy.OutputTypes[0] != wallet.Secp256k1 || policy.OutputTypes[1] != wallet.Schnorr {
+		t.Fatalf("output types are %v", policy.OutputTypes)
+	}
+
+	for _, bad := range []string{`outputtypes = "rsa"`, `maxinputs = 0`, `dustthreshold = -1`, `minrelayfee = 0.000000001`} {
+		if _, err := loadConfig(t, writeConfig(t, bad)); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
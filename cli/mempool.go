@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"golang-blockchain/blockchain"
+	"golang-blockchain/wallet"
+)
+
+func (cli *CommandLine) submitTransaction(chain *blockchain.BlockChain, tx *blockchain.Transaction) {
+	replaced, err := chain.AcceptToMempool(tx, cli.config.Policy())
+	if err != nil {
+		log.Fatalf("Transaction rejected: %s", err)
+	}
+	for _, entry := range replaced {
+		fmt.Printf("Replaced %x, which paid a fee of %s\n", entry.Tx.ID, entry.Fee)
+	}
+	fmt.Printf("Transaction %x is in the mempool, generate a block to confirm it\n", tx.ID)
+}
+
+// getMempool lists the pending transactions in the order a block would take
+// them, by the fee rate of each transaction together with its unconfirmed
+// ancestors.
+func (cli *CommandLine) getMempool() {
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	packages := chain.MempoolPackages()
+	var fees blockchain.Amount
+	for _, p := range packages {
+		entry := p.Entry
+		marker := ""
+		if entry.Tx.Replaceable() {
+			marker = " (replaceable)"
+		}
+		fmt.Printf("%x%s\n", entry.Tx.ID, marker)
+		fmt.Printf("  Fee: %s, size %d, rate %s per 1000 bytes\n", entry.Fee, entry.Size, entry.FeeRate())
+		fmt.Printf("  Package: %d ancestors, %d descendants, fee %s, size %d, rate %s per 1000 bytes\n", len(p.Ancestors), len(p.Descendants), p.Fee, p.Size, p.FeeRate())
+		for _, ancestor := range p.Ancestors {
+			fmt.Printf("    Ancestor: %x\n", ancestor.Tx.ID)
+		}
+		for _, descendant := range p.Descendants {
+			fmt.Printf("    Descendant: %x\n", descendant.Tx.ID)
+		}
+		fmt.Printf("  Received: %s\n", time.Unix(entry.Time, 0).Format(time.RFC3339))
+		fees += entry.Fee
+	}
+	fmt.Printf("%d transactions paying %s in fees\n", len(packages), fees)
+}
+
+// walletOwners maps the locks of the wallet's spendable addresses back to
+// the addresses.
+func (cli *CommandLine) walletOwners(wallets *wallet.Wallets) map[string]string {
+	owners := make(map[string]string)
+	for _, address := range wallets.GetAllAddresses() {
+		addr := cli.validateAddress(address)
+		owners[lockKey(addr.Key, addr.Hash)] = address
+	}
+	return owners
+}
+
+func lockKey(keyType wallet.KeyType, hash []byte) string {
+	return fmt.Sprintf("%d:%x", keyType, hash)
+}
+
+// changeOutput finds the output of tx that returns change to the wallet. It
+// pays an HD change address, or the funding address itself when no change
+// address was derived. Any other output may be a payment, so none is
+// guessed at.
+func changeOutput(wallets *wallet.Wallets, owners map[string]string, tx *blockchain.Transaction, from string) (int, error) {
+	var derived, returned []int
+	for i, output := range tx.Outputs {
+		address := owners[lockKey(output.KeyType, output.PubKeyHash)]
+		if len(output.Asset) > 0 || address == "" {
+			continue
+		}
+		if wallets.Info(address).Purpose == wallet.PurposeChange {
+			derived = append(derived, i)
+		} else if address == from {
+			returned = append(returned, i)
+		}
+	}
+
+	candidates := derived
+	if len(candidates) == 0 {
+		candidates = returned
+	}
+	switch len(candidates) {
+	case 0:
+		return -1, fmt.Errorf("transaction %x has no change output", tx.ID)
+	case 1:
+		return candidates[0], nil
+	}
+	return -1, fmt.Errorf("transaction %x has %d outputs that could be its change", tx.ID, len(candidates))
+}
+
+// bumpFee rebuilds a pending wallet transaction with the same inputs and
+// takes the extra fee from its change.
+func (cli *CommandLine) bumpFee(txid string, fee blockchain.Amount) {
+	id, err := hex.DecodeString(txid)
+	if err != nil {
+		log.Fatalf("Invalid transaction id: %s", err)
+	}
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+
+	entry, err := chain.MempoolEntry(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !entry.Tx.Replaceable() {
+		log.Fatalf("Transaction %x did not signal replaceability", id)
+	}
+	if fee == 0 {
+		fee = entry.Fee + max(cli.config.Policy().RelayFee(entry.Size), 1)
+	}
+	if fee <= entry.Fee {
+		log.Fatalf("The new fee must be higher than the current %s", entry.Fee)
+	}
+
+	wallets := cli.loadWallets()
+	owners := cli.walletOwners(wallets)
+	spent, err := chain.SpentOutputs(entry.Tx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	from := ""
+	for _, output := range spent {
+		address := owners[lockKey(output.KeyType, output.PubKeyHash)]
+		if address == "" || (from != "" && address != from) {
+			log.Fatalf("Transaction %x is not funded by a single address of this wallet", id)
+		}
+		from = address
+	}
+
+	extra := fee - entry.Fee
+	change, err := changeOutput(wallets, owners, entry.Tx, from)
+	if err != nil {
+		log.Fatalf("Cannot bump the fee: %s", err)
+	}
+	if entry.Tx.Outputs[change].Value < extra {
+		log.Fatalf("The change of %x is %s, it cannot pay %s more", id, entry.Tx.Outputs[change].Value, extra)
+	}
+
+	tx := &blockchain.Transaction{Version: blockchain.TxVersion, Issuance: entry.Tx.Issuance}
+	for _, input := range entry.Tx.Inputs {
+		tx.Inputs = append(tx.Inputs, blockchain.TxInput{ID: input.ID, Out: input.Out, Replaceable: input.Replaceable})
+	}
+	for i, output := range entry.Tx.Outputs {
+		if i == change {
+			output.Value -= extra
+			if output.Value == 0 {
+				continue
+			}
+		}
+		tx.Outputs = append(tx.Outputs, output)
+	}
+	tx.ID = tx.Hash()
+
+	cli.unlockWallets(wallets)
+	chain.SignTransaction(tx, wallets.GetWallet(from), blockchain.SigHashAll)
+	fmt.Printf("Raising the fee of %x from %s to %s\n", id, entry.Fee, fee)
+	cli.submitTransaction(chain, tx)
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang-blockchain/blockchain"
+	"golang-blockchain/wallet"
+)
+
+func parsePublicKeys(list string) [][]byte {
+	var keys [][]byte
+	for _, field := range strings.Split(list, ",") {
+		key, err := hex.DecodeString(strings.TrimSpace(field))
+		if err != nil || len(key) == 0 {
+			log.Fatalf("Invalid public key: %s", field)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (cli *CommandLine) musigAddress(keys [][]byte) (string, []byte) {
+	aggregate, err := wallet.AggregateKeys(keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	addr := wallet.Address{Type: wallet.PubKeyHashAddress, Key: wallet.Schnorr, Hash: wallet.PublicKeyHash(aggregate)}
+	return addr.Encode(cli.params), aggregate
+}
+
+func (cli *CommandLine) getPubKey(address string) {
+	address = cli.walletAddress(address)
+	wallets := cli.loadWallets()
+	w, ok := wallets.Wallets[address]
+	if !ok {
+		log.Fatal("Wallet not found")
+	}
+	fmt.Printf("%s %x\n", w.Type, w.PublicKey)
+}
+
+func (cli *CommandLine) createMuSigAddress(publicKeys string) {
+	address, aggregate := cli.musigAddress(parsePublicKeys(publicKeys))
+
+	wallets := cli.loadOrCreateWallets()
+	if _, ok := wallets.Wallets[address]; !ok && !wallets.IsWatchOnly(address) {
+		err := wallets.AddWatchOnly(address, aggregate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = wallets.SaveFile()
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	fmt.Printf("Aggregate key: %x\n", aggregate)
+	fmt.Printf("Address: %s\n", address)
+}
+
+func (cli *CommandLine) musigInit(publicKeys, to string, amount, fee blockchain.Amount, out string) {
+	if cli.validateAddress(to).Type != wallet.PubKeyHashAddress {
+		log.Fatalf("Cannot send to %s: script-hash outputs are not spendable on this chain yet", to)
+	}
+	keys := parsePublicKeys(publicKeys)
+	from, aggregate := cli.musigAddress(keys)
+
+	chain := blockchain.ContinueBlockChain(from, cli.params)
+	defer chain.Database.Close()
+
+	tx := blockchain.NewTransaction(from, aggregate, to, amount, fee, true, nil, chain)
+	session, err := wallet.NewMuSigSession(keys, chain.SigHashes(tx), tx.Serialize())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = session.WriteFile(out)
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("Spending %s from %s, change returns there\n", amount, from)
+	fmt.Printf("Session written to %s, every signer now runs musignonce on it\n", out)
+}
+
+// loadSession reads a session and checks that its digests belong to the
+// transaction it carries, so signers know what they are signing.
+func (cli *CommandLine) loadSession(path string) (*wallet.MuSigSession, *blockchain.Transaction) {
+	session, err := wallet.ReadMuSigSession(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tx := blockchain.DeserializeTransaction(session.Payload)
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+	hashes := chain.SigHashes(&tx)
+	if len(hashes) != len(session.Messages) {
+		log.Fatalf("Session %s does not match its transaction", path)
+	}
+	for i := range hashes {
+		if !bytes.Equal(hashes[i], session.Messages[i]) {
+			log.Fatalf("Session %s does not match its transaction", path)
+		}
+	}
+	fmt.Println(tx)
+	describeSpend(chain, &tx)
+	return session, &tx
+}
+
+func (cli *CommandLine) musigNonce(path, address string) {
+	address = cli.walletAddress(address)
+	session, _ := cli.loadSession(path)
+
+	wallets := cli.loadWallets()
+	cli.unlockWallets(wallets)
+	err := wallets.AddNonces(session, address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	err = session.WriteFile(path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if missing := session.Missing(session.Nonces); len(missing) > 0 {
+		fmt.Printf("Nonces added, still waiting for: %s\n", strings.Join(missing, ", "))
+		return
+	}
+	fmt.Println("Every signer has added nonces, now every signer runs musigsign")
+}
+
+func (cli *CommandLine) musigSign(path, address string) {
+	address = cli.walletAddress(address)
+	session, _ := cli.loadSession(path)
+
+	wallets := cli.loadWallets()
+	cli.unlockWallets(wallets)
+	err := wallets.SignSession(session, address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = wallets.SaveFile()
+	if err != nil {
+		log.Panic(err)
+	}
+	err = session.WriteFile(path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if missing := session.Missing(session.Partials); len(missing) > 0 {
+		fmt.Printf("Signed, still waiting for: %s\n", strings.Join(missing, ", "))
+		return
+	}
+	fmt.Println("Every signer has signed, run musigfinish to broadcast")
+}
+
+func (cli *CommandLine) musigFinish(path string) {
+	session, tx := cli.loadSession(path)
+	signatures, err := session.Signatures()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i := range tx.Inputs {
+		tx.Inputs[i].Signature = signatures[i]
+	}
+
+	chain := blockchain.ContinueBlockChain("", cli.params)
+	defer chain.Database.Close()
+	cli.submitTransaction(chain, tx)
+}
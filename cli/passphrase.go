@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang-blockchain/wallet"
+
+	"golang.org/x/term"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readPassphrase(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Panic(err)
+		}
+		return string(passphrase)
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatal("No passphrase given")
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func readNewPassphrase() string {
+	passphrase := readPassphrase("New passphrase: ")
+	if passphrase != readPassphrase("Repeat new passphrase: ") {
+		log.Fatal("Passphrases do not match")
+	}
+	return passphrase
+}
+
+func (cli *CommandLine) unlockWallets(wallets *wallet.Wallets) {
+	if !wallets.IsLocked() || wallets.UnlockFromAgent() == nil {
+		return
+	}
+
+	err := wallets.Unlock(readPassphrase("Wallet passphrase: "))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -3,8 +3,13 @@ module golang-blockchain
 go 1.21.0
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
 	github.com/dgraph-io/badger v1.6.2
 	github.com/mr-tron/base58 v1.2.0
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/crypto v0.14.0
+	golang.org/x/term v0.13.0
 )
 
 require (
@@ -15,5 +20,5 @@ require (
 	github.com/golang/protobuf v1.3.1 // indirect
 	github.com/pkg/errors v0.8.1 // indirect
 	golang.org/x/net v0.10.0 // indirect
-	golang.org/x/sys v0.11.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,143 @@
+package params
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Params struct {
+	Name              string
+	DataDir           string
+	WalletFile        string
+	GenesisMessage    string
+	Reward            int
+	Difficulty        int
+	MinerThreads      int
+	CoinbaseMaturity  int
+	MaxBlockSize      int
+	MaxTxSize         int
+	MaxTxInputs       int
+	MaxTxOutputs      int
+	MaxBlockSigOps    int
+	StrictSigHeight   int
+	AddressVersion    byte
+	ScriptHashVersion byte
+	PrivateKeyVersion byte
+	Bech32HRP         string
+	Magic             [4]byte
+}
+
+var MainNet = Params{
+	Name:              "mainnet",
+	DataDir:           "./tmp",
+	GenesisMessage:    "Genesis Block Data",
+	Reward:            100,
+	Difficulty:        12,
+	CoinbaseMaturity:  100,
+	MaxBlockSize:      1000000,
+	MaxTxSize:         100000,
+	MaxTxInputs:       1000,
+	MaxTxOutputs:      1000,
+	MaxBlockSigOps:    20000,
+	StrictSigHeight:   1000,
+	AddressVersion:    0x00,
+	ScriptHashVersion: 0x05,
+	PrivateKeyVersion: 0x80,
+	Bech32HRP:         "gb",
+	Magic:             [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+}
+
+var TestNet = Params{
+	Name:              "testnet",
+	DataDir:           "./tmp/testnet",
+	GenesisMessage:    "Testnet Genesis Block Data",
+	Reward:            100,
+	Difficulty:        8,
+	CoinbaseMaturity:  100,
+	MaxBlockSize:      1000000,
+	MaxTxSize:         100000,
+	MaxTxInputs:       1000,
+	MaxTxOutputs:      1000,
+	MaxBlockSigOps:    20000,
+	StrictSigHeight:   500,
+	AddressVersion:    0x6f,
+	ScriptHashVersion: 0xc4,
+	PrivateKeyVersion: 0xef,
+	Bech32HRP:         "tgb",
+	Magic:             [4]byte{0x0b, 0x11, 0x09, 0x07},
+}
+
+var RegTest = Params{
+	Name:              "regtest",
+	DataDir:           "./tmp/regtest",
+	GenesisMessage:    "Regtest Genesis Block Data",
+	Reward:            100,
+	Difficulty:        1,
+	CoinbaseMaturity:  1,
+	MaxBlockSize:      1000000,
+	MaxTxSize:         100000,
+	MaxTxInputs:       1000,
+	MaxTxOutputs:      1000,
+	MaxBlockSigOps:    20000,
+	StrictSigHeight:   0,
+	AddressVersion:    0x6f,
+	ScriptHashVersion: 0xc4,
+	PrivateKeyVersion: 0xef,
+	Bech32HRP:         "gbrt",
+	Magic:             [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+}
+
+func ForNetwork(name string) (*Params, error) {
+	var p Params
+	switch name {
+	case "", MainNet.Name:
+		p = MainNet
+	case TestNet.Name:
+		p = TestNet
+	case RegTest.Name:
+		p = RegTest
+	default:
+		return nil, fmt.Errorf("unknown network %q", name)
+	}
+	return &p, nil
+}
+
+func NetworkForVersion(version byte) string {
+	var names []string
+	for _, p := range []Params{MainNet, TestNet, RegTest} {
+		if p.AddressVersion == version || p.ScriptHashVersion == version {
+			names = append(names, p.Name)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func NetworkForHRP(hrp string) string {
+	for _, p := range []Params{MainNet, TestNet, RegTest} {
+		if p.Bech32HRP == hrp {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func (p *Params) SetDataDir(dir string) {
+	p.DataDir = dir
+	p.WalletFile = ""
+}
+
+func (p *Params) DBPath() string {
+	return filepath.Join(p.DataDir, "blocks")
+}
+
+func (p *Params) DBFile() string {
+	return filepath.Join(p.DBPath(), "MANIFEST")
+}
+
+func (p *Params) WalletPath() string {
+	if p.WalletFile != "" {
+		return p.WalletFile
+	}
+	return filepath.Join(p.DataDir, "wallets.data")
+}
@@ -0,0 +1,56 @@
+package params
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestForNetwork(t *testing.T) {
+	for _, name := range []string{"", "mainnet", "testnet", "regtest"} {
+		p, err := ForNetwork(name)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if want := name; want != "" && p.Name != want {
+			t.Errorf("%q: got network %s", name, p.Name)
+		}
+	}
+	if p, _ := ForNetwork(""); p.Name != MainNet.Name {
+		t.Errorf("the default network is %s, want %s", p.Name, MainNet.Name)
+	}
+	if _, err := ForNetwork("simnet"); err == nil {
+		t.Error("an unknown network was accepted")
+	}
+}
+
+func TestForNetworkCopiesThePreset(t *testing.T) {
+	p, err := ForNetwork("regtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetDataDir("elsewhere")
+	p.Reward = 1
+	if RegTest.DataDir == "elsewhere" || RegTest.Reward == 1 {
+		t.Fatal("changing the returned params changed the regtest preset")
+	}
+}
+
+func TestDataDirPaths(t *testing.T) {
+	p := TestNet
+	p.WalletFile = "custom.data"
+	p.SetDataDir("node")
+	if got, want := p.DBPath(), filepath.Join("node", "blocks"); got != want {
+		t.Errorf("DBPath() = %s, want %s", got, want)
+	}
+	if got, want := p.DBFile(), filepath.Join("node", "blocks", "MANIFEST"); got != want {
+		t.Errorf("DBFile() = %s, want %s", got, want)
+	}
+	if got, want := p.WalletPath(), filepath.Join("node", "wallets.data"); got != want {
+		t.Errorf("WalletPath() = %s, want %s", got, want)
+	}
+
+	p.WalletFile = "custom.data"
+	if got := p.WalletPath(); got != "custom.data" {
+		t.Errorf("WalletPath() = %s, want the wallet file", got)
+	}
+}
@@ -0,0 +1,225 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang-blockchain/params"
+
+	"github.com/mr-tron/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+type AddressType byte
+
+const (
+	PubKeyHashAddress AddressType = iota
+	ScriptHashAddress
+)
+
+const hash160Length = ripemd160.Size
+
+var (
+	ErrAddressEncoding = errors.New("address is not valid Base58")
+	ErrAddressLength   = errors.New("address has the wrong length")
+	ErrAddressChecksum = errors.New("address checksum does not match, it was probably mistyped")
+	ErrAddressNetwork  = errors.New("address belongs to another network")
+	ErrAddressVersion  = errors.New("address has an unknown version byte")
+)
+
+// Key-hash addresses for keys other than P256 carry the key type right
+// after the version, P256 addresses keep their original layout.
+type Address struct {
+	Type AddressType
+	Key  KeyType
+	Hash []byte
+}
+
+func (t AddressType) String() string {
+	if t == ScriptHashAddress {
+		return "script-hash"
+	}
+	return "key-hash"
+}
+
+func Hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	hasher := ripemd160.New()
+	hasher.Write(sha[:])
+	return hasher.Sum(nil)
+}
+
+// Addresses created before HASH160 carry a bare SHA-256 of the public key,
+// so matching goes by the length of the hash being compared against. The
+// key may also have been hashed in another encoding than the one given.
+func MatchesPublicKey(hash, publicKey []byte) bool {
+	encodings := [][]byte{publicKey}
+	if key, err := ParseLegacyPublicKey(publicKey); err == nil {
+		encodings = append(encodings, PublicKeyEncodings(key)...)
+	}
+
+	for _, encoded := range encodings {
+		if len(hash) == sha256.Size {
+			legacy := sha256.Sum256(encoded)
+			if bytes.Equal(hash, legacy[:]) {
+				return true
+			}
+		} else if bytes.Equal(hash, Hash160(encoded)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBech32Address(address string) bool {
+	lower := strings.ToLower(address)
+	for _, p := range []params.Params{params.MainNet, params.TestNet, params.RegTest} {
+		if strings.HasPrefix(lower, p.Bech32HRP+"1") {
+			return true
+		}
+	}
+	return false
+}
+
+func DecodeAddress(address string, p *params.Params) (*Address, error) {
+	if isBech32Address(address) {
+		hrp := strings.ToLower(address[:strings.LastIndexByte(address, '1')])
+		if hrp != p.Bech32HRP {
+			return nil, fmt.Errorf("%w: it is a %s address, this is %s", ErrAddressNetwork, params.NetworkForHRP(hrp), p.Name)
+		}
+		return decodeBech32Address(address)
+	}
+
+	decoded, err := base58.Decode(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAddressEncoding, err)
+	}
+	if len(decoded) <= 1+checksumLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrAddressLength, len(decoded))
+	}
+
+	payload := decoded[:len(decoded)-checksumLength]
+	if !bytes.Equal(Checksum(payload), decoded[len(decoded)-checksumLength:]) {
+		return nil, ErrAddressChecksum
+	}
+
+	var addr Address
+	switch version := payload[0]; version {
+	case p.AddressVersion:
+		addr.Type = PubKeyHashAddress
+	case p.ScriptHashVersion:
+		addr.Type = ScriptHashAddress
+	default:
+		if network := params.NetworkForVersion(version); network != "" {
+			return nil, fmt.Errorf("%w: it is a %s address, this is %s", ErrAddressNetwork, network, p.Name)
+		}
+		return nil, fmt.Errorf("%w: 0x%02x", ErrAddressVersion, version)
+	}
+
+	parsed, err := decodeBase58Payload(payload[1:])
+	if err != nil {
+		return nil, err
+	}
+	legacy := len(parsed.Hash) == sha256.Size
+	if addr.Type == ScriptHashAddress && (parsed.Key != P256 || legacy) {
+		return nil, fmt.Errorf("%w: %d bytes is not a script hash", ErrAddressLength, len(payload)-1)
+	}
+	addr.Key, addr.Hash = parsed.Key, parsed.Hash
+	return &addr, nil
+}
+
+func decodeBase58Payload(payload []byte) (*Address, error) {
+	var addr Address
+	switch len(payload) {
+	case hash160Length, sha256.Size:
+		addr.Hash = payload
+	case 1 + hash160Length:
+		addr.Key, addr.Hash = KeyType(payload[0]), payload[1:]
+		if _, err := SchemeFor(addr.Key); err != nil || addr.Key == P256 {
+			return nil, fmt.Errorf("%w: key type 0x%02x", ErrAddressVersion, payload[0])
+		}
+	default:
+		return nil, fmt.Errorf("%w: the hash is %d bytes, expected %d", ErrAddressLength, len(payload), hash160Length)
+	}
+	return &addr, nil
+}
+
+func (a *Address) Encode(p *params.Params) string {
+	version := p.AddressVersion
+	if a.Type == ScriptHashAddress {
+		version = p.ScriptHashVersion
+	}
+	return a.encode(version)
+}
+
+func (a *Address) encode(version byte) string {
+	payload := []byte{version}
+	if a.Key != P256 {
+		payload = append(payload, byte(a.Key))
+	}
+	payload = append(payload, a.Hash...)
+	return string(Base58Encode(append(payload, Checksum(payload)...)))
+}
+
+// Bech32 addresses carry the address type as their first 5-bit group:
+// key-hash addresses use Bech32 and script-hash addresses use Bech32m. A
+// 160-bit hash fills exactly 32 groups, so an odd group before it is the
+// key type.
+func decodeBech32Address(address string) (*Address, error) {
+	_, data, variant, err := Bech32Decode(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 {
+		return nil, fmt.Errorf("%w: no address data", ErrAddressLength)
+	}
+
+	addr := Address{Type: AddressType(data[0])}
+	switch {
+	case addr.Type == PubKeyHashAddress && variant == Bech32:
+	case addr.Type == ScriptHashAddress && variant == Bech32m:
+	default:
+		return nil, fmt.Errorf("%w: type %d with this checksum variant", ErrAddressVersion, data[0])
+	}
+
+	program := data[1:]
+	if len(program)%8 != 0 {
+		addr.Key = KeyType(program[0])
+		program = program[1:]
+		if _, err := SchemeFor(addr.Key); err != nil || addr.Key == P256 || addr.Type != PubKeyHashAddress {
+			return nil, fmt.Errorf("%w: key type %d", ErrAddressVersion, addr.Key)
+		}
+	}
+
+	addr.Hash, err = convertBits(program, 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(addr.Hash) != hash160Length {
+		return nil, fmt.Errorf("%w: the hash is %d bytes, expected %d", ErrAddressLength, len(addr.Hash), hash160Length)
+	}
+	return &addr, nil
+}
+
+func (a *Address) EncodeBech32(p *params.Params) (string, error) {
+	if len(a.Hash) != hash160Length {
+		return "", fmt.Errorf("%w: legacy addresses have no bech32 form", ErrAddressLength)
+	}
+
+	data, err := convertBits(a.Hash, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	variant := Bech32
+	if a.Type == ScriptHashAddress {
+		variant = Bech32m
+	}
+	prefix := []byte{byte(a.Type)}
+	if a.Key != P256 {
+		prefix = append(prefix, byte(a.Key))
+	}
+	return Bech32Encode(p.Bech32HRP, append(prefix, data...), variant), nil
+}
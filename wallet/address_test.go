@@ -0,0 +1,71 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestHash160(t *testing.T) {
+	// HASH160 of the compressed generator point of secp256k1, the key
+	// behind Bitcoin address 1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH.
+	publicKey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	if got, want := hex.EncodeToString(Hash160(publicKey)), "751e76e8199196d454941c45d1b3a323f1433bd6"; got != want {
+		t.Fatalf("Hash160() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeAddress(t *testing.T) {
+	p := &params.RegTest
+	w := MakeWallet(P256)
+	keyHash := string(w.Address(p.AddressVersion))
+	scriptHash := (&Address{Type: ScriptHashAddress, Hash: Hash160([]byte("script"))}).Encode(p)
+	legacyHash := sha256.Sum256(w.PublicKey)
+	legacy := (&Address{Type: PubKeyHashAddress, Hash: legacyHash[:]}).Encode(p)
+
+	for address, want := range map[string]AddressType{keyHash: PubKeyHashAddress, scriptHash: ScriptHashAddress, legacy: PubKeyHashAddress} {
+		addr, err := DecodeAddress(address, p)
+		if err != nil {
+			t.Fatalf("%s: %v", address, err)
+		}
+		if addr.Type != want || addr.Encode(p) != address {
+			t.Errorf("%s decoded to a %s address that encodes as %s", address, addr.Type, addr.Encode(p))
+		}
+	}
+	if addr, _ := DecodeAddress(keyHash, p); !bytes.Equal(addr.Hash, PublicKeyHash(w.PublicKey)) {
+		t.Error("a key-hash address does not carry the HASH160 of its key")
+	}
+
+	mistyped := []byte(keyHash)
+	if mistyped[5] == 'a' {
+		mistyped[5] = 'b'
+	} else {
+		mistyped[5] = 'a'
+	}
+	unknown := append([]byte{0x42}, PublicKeyHash(w.PublicKey)...)
+	unknown = append(unknown, Checksum(unknown)...)
+	short := append([]byte{p.AddressVersion}, PublicKeyHash(w.PublicKey)[:10]...)
+	short = append(short, Checksum(short)...)
+
+	tests := []struct {
+		name    string
+		address string
+		want    error
+	}{
+		{"a mistyped character", string(mistyped), ErrAddressChecksum},
+		{"a mainnet address", string(w.Address(params.MainNet.AddressVersion)), ErrAddressNetwork},
+		{"an unknown version", string(Base58Encode(unknown)), ErrAddressVersion},
+		{"a short hash", string(Base58Encode(short)), ErrAddressLength},
+		{"no payload", string(Base58Encode([]byte{p.AddressVersion})), ErrAddressLength},
+		{"characters outside Base58", "0OIl", ErrAddressEncoding},
+	}
+	for _, test := range tests {
+		if _, err := DecodeAddress(test.address, p); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
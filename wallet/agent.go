@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang-blockchain/params"
+)
+
+// A timed unlock keeps the derived key in the memory of an agent process that
+// answers on a unix socket next to the wallet file and forgets the key when the
+// timeout passes or walletlock asks it to. The key is never written to disk.
+
+const agentRequestTimeout = 5 * time.Second
+
+var ErrNoAgent = errors.New("no unlocked wallet agent is running")
+
+func agentPath(p *params.Params) string {
+	return p.WalletPath() + ".agent"
+}
+
+// ServeUnlock hands the key of the unlocked wallet to UnlockFromAgent callers
+// until the timeout passes or LockAgent is called, then locks the wallet.
+// ready is called once the socket accepts connections.
+func (ws *Wallets) ServeUnlock(timeout time.Duration, ready func()) error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	defer ws.Lock()
+
+	// Only one agent serves a wallet, a newer unlock replaces an older one.
+	err := LockAgent(ws.params)
+	if err != nil {
+		return err
+	}
+	path := agentPath(ws.params)
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		return err
+	}
+	err = listener.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+	ready()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ws.answerAgentRequest(conn) {
+			return nil
+		}
+	}
+}
+
+// answerAgentRequest answers one request and reports whether it was to lock.
+func (ws *Wallets) answerAgentRequest(conn net.Conn) bool {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentRequestTimeout))
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.TrimSpace(request) {
+	case "key":
+		conn.Write([]byte(hex.EncodeToString(ws.key) + "\n"))
+	case "lock":
+		conn.Write([]byte("ok\n"))
+		return true
+	}
+	return false
+}
+
+func askAgent(p *params.Params, request string) (string, error) {
+	conn, err := net.DialTimeout("unix", agentPath(p), agentRequestTimeout)
+	if err != nil {
+		return "", ErrNoAgent
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentRequestTimeout))
+
+	_, err = conn.Write([]byte(request + "\n"))
+	if err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// UnlockFromAgent unlocks the wallet with the key held by a running agent.
+func (ws *Wallets) UnlockFromAgent() error {
+	if !ws.IsLocked() {
+		return nil
+	}
+
+	reply, err := askAgent(ws.params, "key")
+	if err != nil {
+		return err
+	}
+	key, err := hex.DecodeString(reply)
+	if err != nil {
+		return err
+	}
+	return ws.unlockWithKey(key)
+}
+
+// LockAgent stops the agent of the wallet, if one is running.
+func LockAgent(p *params.Params) error {
+	_, err := askAgent(p, "lock")
+	if err == ErrNoAgent {
+		return nil
+	}
+	return err
+}
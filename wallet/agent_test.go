@@ -0,0 +1,112 @@
+package wallet
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// startAgent unlocks a copy of the wallet and serves it until the returned
+// channel yields the result of ServeUnlock.
+func startAgent(t *testing.T, wallets *Wallets, timeout time.Duration) <-chan error {
+	t.Helper()
+	if err := wallets.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- wallets.ServeUnlock(timeout, func() { close(ready) }) }()
+	select {
+	case <-ready:
+	case err := <-done:
+		t.Fatal(err)
+	}
+	return done
+}
+
+func waitForAgent(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the agent is still running")
+	}
+}
+
+func TestUnlockAgentHandsOutTheKeyUntilLocked(t *testing.T) {
+	p, address, privateKey := newEncryptedWallets(t)
+	agent := loadWallets(t, p)
+	done := startAgent(t, agent, time.Minute)
+
+	wallets := loadWallets(t, p)
+	if err := wallets.UnlockFromAgent(); err != nil {
+		t.Fatal(err)
+	}
+	if wallets.IsLocked() || !bytes.Equal(wallets.Wallets[address].PrivateKey, privateKey) {
+		t.Fatal("the agent did not unlock the wallet")
+	}
+	checkNoKeysOnDisk(t, p, wallets.key, privateKey)
+
+	if err := LockAgent(p); err != nil {
+		t.Fatal(err)
+	}
+	waitForAgent(t, done)
+	if !agent.IsLocked() {
+		t.Fatal("the agent kept its key after walletlock")
+	}
+	if _, err := os.Stat(agentPath(p)); !os.IsNotExist(err) {
+		t.Fatal("the agent socket is still there")
+	}
+	if err := loadWallets(t, p).UnlockFromAgent(); err != ErrNoAgent {
+		t.Fatalf("unlocking after walletlock: got %v, want %v", err, ErrNoAgent)
+	}
+	if err := LockAgent(p); err != nil {
+		t.Fatalf("locking without an agent: %v", err)
+	}
+}
+
+func TestUnlockAgentForgetsTheKeyAtTheTimeout(t *testing.T) {
+	p, _, _ := newEncryptedWallets(t)
+	agent := loadWallets(t, p)
+	done := startAgent(t, agent, 200*time.Millisecond)
+
+	waitForAgent(t, done)
+	if !agent.IsLocked() {
+		t.Fatal("the agent kept its key after the timeout")
+	}
+	if err := loadWallets(t, p).UnlockFromAgent(); err != ErrNoAgent {
+		t.Fatalf("unlocking after the timeout: got %v, want %v", err, ErrNoAgent)
+	}
+}
+
+func TestUnlockAgentReplacesARunningAgent(t *testing.T) {
+	p, _, _ := newEncryptedWallets(t)
+	first := startAgent(t, loadWallets(t, p), time.Minute)
+	second := startAgent(t, loadWallets(t, p), time.Minute)
+
+	waitForAgent(t, first)
+	if err := loadWallets(t, p).UnlockFromAgent(); err != nil {
+		t.Fatalf("the newer agent does not answer: %v", err)
+	}
+	if err := LockAgent(p); err != nil {
+		t.Fatal(err)
+	}
+	waitForAgent(t, second)
+}
+
+func TestServeUnlockNeedsAnUnlockedWallet(t *testing.T) {
+	p, _, _ := newEncryptedWallets(t)
+	if err := loadWallets(t, p).ServeUnlock(time.Minute, func() {}); err != ErrWalletLocked {
+		t.Fatalf("serving a locked wallet: got %v, want %v", err, ErrWalletLocked)
+	}
+
+	plain, _ := newTestWallets(t)
+	plain.AddWallet(P256)
+	if err := plain.ServeUnlock(time.Minute, func() {}); err != ErrNotEncrypted {
+		t.Fatalf("serving a plain wallet: got %v, want %v", err, ErrNotEncrypted)
+	}
+}
@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Bech32Variant int
+
+const (
+	Bech32 Bech32Variant = iota + 1
+	Bech32m
+)
+
+const (
+	bech32Charset   = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32MaxLength = 90
+	bech32ChecksumN = 6
+	bech32Const     = 1
+	bech32mConst    = 0x2bc830a3
+)
+
+var ErrBech32 = errors.New("invalid bech32 string")
+
+type Bech32Error struct {
+	Position int
+	Reason   string
+}
+
+func (e *Bech32Error) Error() string {
+	if e.Position < 0 {
+		return fmt.Sprintf("%s: %s", ErrBech32, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s at position %d", ErrBech32, e.Reason, e.Position)
+}
+
+func (e *Bech32Error) Unwrap() error {
+	return ErrBech32
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func (v Bech32Variant) constant() uint32 {
+	if v == Bech32m {
+		return bech32mConst
+	}
+	return bech32Const
+}
+
+func bech32Checksum(hrp string, data []byte, variant Bech32Variant) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, make([]byte, bech32ChecksumN)...)
+	mod := bech32Polymod(values) ^ variant.constant()
+
+	checksum := make([]byte, bech32ChecksumN)
+	for i := range checksum {
+		checksum[i] = byte(mod>>(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+func bech32Verify(hrp string, data []byte) Bech32Variant {
+	switch bech32Polymod(append(bech32HRPExpand(hrp), data...)) {
+	case bech32Const:
+		return Bech32
+	case bech32mConst:
+		return Bech32m
+	}
+	return 0
+}
+
+func Bech32Encode(hrp string, data []byte, variant Bech32Variant) string {
+	combined := append(append([]byte{}, data...), bech32Checksum(hrp, data, variant)...)
+
+	var encoded strings.Builder
+	encoded.WriteString(hrp)
+	encoded.WriteByte('1')
+	for _, v := range combined {
+		encoded.WriteByte(bech32Charset[v])
+	}
+	return encoded.String()
+}
+
+func Bech32Decode(encoded string) (string, []byte, Bech32Variant, error) {
+	if len(encoded) > bech32MaxLength {
+		return "", nil, 0, &Bech32Error{-1, fmt.Sprintf("longer than %d characters", bech32MaxLength)}
+	}
+
+	lower, upper := false, false
+	for i := 0; i < len(encoded); i++ {
+		c := encoded[i]
+		if c < 33 || c > 126 {
+			return "", nil, 0, &Bech32Error{i, "invalid character"}
+		}
+		lower = lower || (c >= 'a' && c <= 'z')
+		upper = upper || (c >= 'A' && c <= 'Z')
+		if lower && upper {
+			return "", nil, 0, &Bech32Error{i, "mixed upper and lower case"}
+		}
+	}
+	encoded = strings.ToLower(encoded)
+
+	separator := strings.LastIndexByte(encoded, '1')
+	if separator < 1 {
+		return "", nil, 0, &Bech32Error{-1, "missing human-readable prefix"}
+	}
+	if separator+bech32ChecksumN+1 > len(encoded) {
+		return "", nil, 0, &Bech32Error{-1, "too short for a checksum"}
+	}
+
+	hrp := encoded[:separator]
+	data := make([]byte, 0, len(encoded)-separator-1)
+	for i := separator + 1; i < len(encoded); i++ {
+		v := strings.IndexByte(bech32Charset, encoded[i])
+		if v < 0 {
+			return "", nil, 0, &Bech32Error{i, fmt.Sprintf("character %q is not in the bech32 alphabet", encoded[i])}
+		}
+		data = append(data, byte(v))
+	}
+
+	variant := bech32Verify(hrp, data)
+	if variant == 0 {
+		return "", nil, 0, bech32LocateError(hrp, data, separator+1)
+	}
+	return hrp, data[:len(data)-bech32ChecksumN], variant, nil
+}
+
+// A single mistyped character is by far the most common mistake, so try
+// every substitution and point at the one that makes the checksum valid.
+func bech32LocateError(hrp string, data []byte, offset int) error {
+	candidate := append([]byte{}, data...)
+	for i := range candidate {
+		original := candidate[i]
+		for v := byte(0); v < 32; v++ {
+			if v == original {
+				continue
+			}
+			candidate[i] = v
+			if bech32Verify(hrp, candidate) != 0 {
+				return &Bech32Error{offset + i, "checksum mismatch, probably a mistyped character"}
+			}
+		}
+		candidate[i] = original
+	}
+	return &Bech32Error{-1, "checksum mismatch"}
+}
+
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var converted []byte
+	acc, bits := uint(0), uint(0)
+	maxValue := uint(1)<<toBits - 1
+
+	for _, v := range data {
+		if uint(v)>>fromBits != 0 {
+			return nil, &Bech32Error{-1, "data value out of range"}
+		}
+		acc = acc<<fromBits | uint(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, &Bech32Error{-1, "invalid padding"}
+	}
+	return converted, nil
+}
@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+// Test vectors from BIP173 and BIP350.
+var validBech32 = []struct {
+	encoded string
+	variant Bech32Variant
+}{
+	{"A12UEL5L", Bech32},
+	{"a12uel5l", Bech32},
+	{"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs", Bech32},
+	{"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", Bech32},
+	{"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j", Bech32},
+	{"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w", Bech32},
+	{"?1ezyfcl", Bech32},
+	{"A1LQFN3A", Bech32m},
+	{"a1lqfn3a", Bech32m},
+	{"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6", Bech32m},
+	{"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", Bech32m},
+	{"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8", Bech32m},
+	{"split1checkupstagehandshakeupstreamerranterredcaperredlc445v", Bech32m},
+	{"?1v759aa", Bech32m},
+}
+
+var invalidBech32 = []struct {
+	encoded string
+	reason  string
+}{
+	{"\x201nwldj5", "prefix character out of range"},
+	{"\x7f1axkwrx", "prefix character out of range"},
+	{"\x801eym55h", "prefix character out of range"},
+	{"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", "overall length exceeded"},
+	{"pzry9x0s0muk", "no separator"},
+	{"1pzry9x0s0muk", "empty prefix"},
+	{"x1b4n0q5v", "invalid data character"},
+	{"li1dgmt3", "checksum too short"},
+	{"de1lg7wt\xff", "invalid checksum character"},
+	{"A1G7SGD8", "checksum over an upper case prefix"},
+	{"10a06t8", "empty prefix"},
+	{"1qzzfhee", "empty prefix"},
+	{"\x201xj0phk", "prefix character out of range"},
+	{"\x7f1g6xzxy", "prefix character out of range"},
+	{"\x801vctc34", "prefix character out of range"},
+	{"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4", "overall length exceeded"},
+	{"qyrz8wqd2c9m", "no separator"},
+	{"1qyrz8wqd2c9m", "empty prefix"},
+	{"y1b0jsk6g", "invalid data character"},
+	{"lt1igcx5c0", "invalid data character"},
+	{"in1muywd", "checksum too short"},
+	{"mm1crxm3i", "invalid checksum character"},
+	{"au1s5cgom", "invalid checksum character"},
+	{"M1VUXWEZ", "checksum over an upper case prefix"},
+	{"16plkw9", "empty prefix"},
+	{"1p2gdwpf", "empty prefix"},
+}
+
+func TestBech32Valid(t *testing.T) {
+	for _, test := range validBech32 {
+		hrp, data, variant, err := Bech32Decode(test.encoded)
+		if err != nil {
+			t.Errorf("%q: %v", test.encoded, err)
+			continue
+		}
+		if variant != test.variant {
+			t.Errorf("%q: got variant %d, want %d", test.encoded, variant, test.variant)
+		}
+		if encoded := Bech32Encode(hrp, data, variant); encoded != strings.ToLower(test.encoded) {
+			t.Errorf("%q: re-encodes as %q", test.encoded, encoded)
+		}
+	}
+}
+
+func TestBech32Invalid(t *testing.T) {
+	for _, test := range invalidBech32 {
+		_, _, _, err := Bech32Decode(test.encoded)
+		if !errors.Is(err, ErrBech32) {
+			t.Errorf("%q (%s): got %v, want %v", test.encoded, test.reason, err, ErrBech32)
+		}
+	}
+}
+
+func TestBech32LocatesMistypedCharacter(t *testing.T) {
+	for _, test := range validBech32 {
+		encoded := strings.ToLower(test.encoded)
+		separator := strings.LastIndexByte(encoded, '1')
+		for i := separator + 1; i < len(encoded); i++ {
+			mistyped := []byte(encoded)
+			original := strings.IndexByte(bech32Charset, encoded[i])
+			mistyped[i] = bech32Charset[(original+1)%len(bech32Charset)]
+
+			_, _, _, err := Bech32Decode(string(mistyped))
+			var bech32Err *Bech32Error
+			if !errors.As(err, &bech32Err) || bech32Err.Position != i {
+				t.Errorf("%q with position %d mistyped: got %v", test.encoded, i, err)
+			}
+		}
+	}
+}
+
+func TestBech32AddressRoundTrip(t *testing.T) {
+	p := &params.RegTest
+	hash := Hash160([]byte("key"))
+	addresses := []Address{
+		{Type: PubKeyHashAddress, Key: P256, Hash: hash},
+		{Type: PubKeyHashAddress, Key: Secp256k1, Hash: hash},
+		{Type: PubKeyHashAddress, Key: Ed25519, Hash: hash},
+		{Type: PubKeyHashAddress, Key: Schnorr, Hash: hash},
+		{Type: ScriptHashAddress, Key: P256, Hash: hash},
+	}
+
+	for _, addr := range addresses {
+		encoded, err := addr.EncodeBech32(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(encoded, p.Bech32HRP+"1") {
+			t.Errorf("%s does not start with %s1", encoded, p.Bech32HRP)
+		}
+		for _, form := range []string{encoded, strings.ToUpper(encoded)} {
+			decoded, err := DecodeAddress(form, p)
+			if err != nil {
+				t.Errorf("%s: %v", form, err)
+				continue
+			}
+			if decoded.Type != addr.Type || decoded.Key != addr.Key || !bytes.Equal(decoded.Hash, addr.Hash) {
+				t.Errorf("%s decodes as %+v, want %+v", form, decoded, addr)
+			}
+		}
+	}
+}
+
+func TestBech32AddressErrors(t *testing.T) {
+	hash := Hash160([]byte("key"))
+	addr := Address{Type: PubKeyHashAddress, Hash: hash}
+	encoded, err := addr.EncodeBech32(&params.RegTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeAddress(encoded, &params.MainNet); !errors.Is(err, ErrAddressNetwork) {
+		t.Errorf("regtest address on mainnet: got %v, want %v", err, ErrAddressNetwork)
+	}
+
+	// A key-hash address has to carry a Bech32 checksum, not Bech32m.
+	data, err := convertBits(hash, 8, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongVariant := Bech32Encode(params.RegTest.Bech32HRP, append([]byte{byte(PubKeyHashAddress)}, data...), Bech32m)
+	if _, err := DecodeAddress(wrongVariant, &params.RegTest); !errors.Is(err, ErrAddressVersion) {
+		t.Errorf("key-hash address with a Bech32m checksum: got %v, want %v", err, ErrAddressVersion)
+	}
+
+	legacy := Address{Type: PubKeyHashAddress, Hash: make([]byte, 32)}
+	if _, err := legacy.EncodeBech32(&params.RegTest); !errors.Is(err, ErrAddressLength) {
+		t.Errorf("encoding a SHA-256 address: got %v, want %v", err, ErrAddressLength)
+	}
+}
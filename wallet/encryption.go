@@ -0,0 +1,251 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	keyLength  = 32
+	saltLength = 16
+)
+
+var checkPlaintext = []byte("golang-blockchain wallet")
+
+var (
+	ErrWalletLocked       = errors.New("wallet is locked, unlock it with walletpassphrase")
+	ErrWrongPassphrase    = errors.New("incorrect wallet passphrase")
+	ErrNotEncrypted       = errors.New("wallet is not encrypted")
+	ErrAlreadyEncrypted   = errors.New("wallet is already encrypted")
+	ErrEmptyPassphrase    = errors.New("passphrase must not be empty")
+	ErrCorruptedEncrypted = errors.New("encrypted private key is corrupted")
+)
+
+type Encryption struct {
+	Salt  []byte
+	Check []byte
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func unseal(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrCorruptedEncrypted
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+}
+
+func newEncryption(passphrase string) (*Encryption, []byte, error) {
+	if passphrase == "" {
+		return nil, nil, ErrEmptyPassphrase
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	check, err := seal(key, checkPlaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Encryption{Salt: salt, Check: check}, key, nil
+}
+
+func (e *Encryption) verifyKey(key []byte) bool {
+	plaintext, err := unseal(key, e.Check)
+	return err == nil && bytes.Equal(plaintext, checkPlaintext)
+}
+
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.encryption != nil
+}
+
+func (ws *Wallets) IsLocked() bool {
+	return ws.encryption != nil && ws.key == nil
+}
+
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return ErrAlreadyEncrypted
+	}
+
+	encryption, key, err := newEncryption(passphrase)
+	if err != nil {
+		return err
+	}
+	err = ws.resealNonces(nil, key)
+	if err != nil {
+		return err
+	}
+	ws.encryption = encryption
+	ws.key = key
+	ws.sealedKeys = make(map[string][]byte)
+	return nil
+}
+
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+
+	key, err := deriveKey(passphrase, ws.encryption.Salt)
+	if err != nil {
+		return err
+	}
+	return ws.unlockWithKey(key)
+}
+
+func (ws *Wallets) unlockWithKey(key []byte) error {
+	if !ws.encryption.verifyKey(key) {
+		return ErrWrongPassphrase
+	}
+
+	for address, sealed := range ws.sealedKeys {
+		privateKey, err := unseal(key, sealed)
+		if err != nil {
+			return ErrCorruptedEncrypted
+		}
+		keyType := ws.Wallets[address].Type
+		wallet := FromSerializable(SerializableWallet{
+			Type:       keyType,
+			PrivateKey: padPrivateKey(keyType, privateKey),
+			PublicKey:  ws.Wallets[address].PublicKey,
+		})
+		ws.Wallets[address] = &wallet
+	}
+	if ws.sealedSeed != nil {
+		seed, err := unseal(key, ws.sealedSeed)
+		if err != nil {
+			return ErrCorruptedEncrypted
+		}
+		ws.hd.Seed = seed
+	}
+	if ws.sealedMnemonic != nil {
+		mnemonic, err := unseal(key, ws.sealedMnemonic)
+		if err != nil {
+			return ErrCorruptedEncrypted
+		}
+		ws.hd.Mnemonic = mnemonic
+	}
+	ws.key = key
+	return nil
+}
+
+func (ws *Wallets) Lock() error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+
+	for i := range ws.key {
+		ws.key[i] = 0
+	}
+	ws.key = nil
+	for address, wallet := range ws.Wallets {
+		ws.Wallets[address] = &Wallet{Type: wallet.Type, PublicKey: wallet.PublicKey}
+	}
+	if ws.hd != nil {
+		ws.hd.Seed = nil
+		ws.hd.Mnemonic = nil
+	}
+	return nil
+}
+
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	err := ws.Unlock(oldPassphrase)
+	if err != nil {
+		return err
+	}
+
+	encryption, key, err := newEncryption(newPassphrase)
+	if err != nil {
+		return err
+	}
+	err = ws.resealNonces(ws.key, key)
+	if err != nil {
+		return err
+	}
+	ws.encryption = encryption
+	ws.key = key
+	ws.sealedKeys = make(map[string][]byte)
+	return nil
+}
+
+func (ws *Wallets) sealPrivateKey(address string, wallet *Wallet) ([]byte, error) {
+	if wallet.PrivateKey == nil || ws.key == nil {
+		if sealed, ok := ws.sealedKeys[address]; ok {
+			return sealed, nil
+		}
+		return nil, ErrWalletLocked
+	}
+	return seal(ws.key, wallet.PrivateKey)
+}
+
+func (ws *Wallets) sealSecret(plaintext, sealed []byte) ([]byte, error) {
+	if plaintext == nil || ws.key == nil {
+		if sealed != nil {
+			return sealed, nil
+		}
+		return nil, ErrWalletLocked
+	}
+	return seal(ws.key, plaintext)
+}
+
+// Pending MuSig nonces are secret too and follow the wallet key.
+func (ws *Wallets) resealNonces(oldKey, newKey []byte) error {
+	for id, secrets := range ws.Nonces {
+		var err error
+		if oldKey != nil {
+			secrets, err = unseal(oldKey, secrets)
+			if err != nil {
+				return ErrCorruptedEncrypted
+			}
+		}
+		ws.Nonces[id], err = seal(newKey, secrets)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func newTestWallets(t *testing.T) (*Wallets, *params.Params) {
+	t.Helper()
+	p := params.RegTest
+	p.SetDataDir(t.TempDir())
+	wallets, err := CreateWallets(&p)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return wallets, &p
+}
+
+// newEncryptedWallets saves a wallet with one address encrypted under pw and
+// returns the address and its private key.
+func newEncryptedWallets(t *testing.T) (*params.Params, string, []byte) {
+	t.Helper()
+	wallets, p := newTestWallets(t)
+	address := wallets.AddWallet(P256)
+	privateKey := wallets.Wallets[address].PrivateKey
+	if err := wallets.Encrypt("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	return p, address, privateKey
+}
+
+func loadWallets(t *testing.T, p *params.Params) *Wallets {
+	t.Helper()
+	wallets, err := CreateWallets(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallets
+}
+
+// checkNoKeysOnDisk fails if a file in the data directory holds one of keys.
+func checkNoKeysOnDisk(t *testing.T, p *params.Params, keys ...[]byte) {
+	t.Helper()
+	entries, err := os.ReadDir(p.DataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(p.DataDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			if bytes.Contains(content, key) {
+				t.Fatalf("%s holds the wallet key or a private key in plaintext", entry.Name())
+			}
+		}
+	}
+}
+
+func TestEncryptedWalletNeverStoresItsKey(t *testing.T) {
+	p, address, privateKey := newEncryptedWallets(t)
+
+	loaded := loadWallets(t, p)
+	if !loaded.IsLocked() {
+		t.Fatal("a loaded encrypted wallet is unlocked without its passphrase")
+	}
+	if loaded.Wallets[address].PrivateKey != nil {
+		t.Fatal("a locked wallet holds a private key")
+	}
+	if err := loaded.SaveFile(); err != nil {
+		t.Fatalf("saving a locked wallet: %v", err)
+	}
+	if err := loaded.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("unlocking with a wrong passphrase: got %v, want %v", err, ErrWrongPassphrase)
+	}
+	if err := loaded.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Wallets[address].PrivateKey, privateKey) {
+		t.Fatal("unlocking did not restore the private key")
+	}
+	checkNoKeysOnDisk(t, p, loaded.key, privateKey)
+
+	if err := loaded.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.IsLocked() || loaded.Wallets[address].PrivateKey != nil {
+		t.Fatal("locking left the private key in memory")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	p, address, privateKey := newEncryptedWallets(t)
+
+	wallets := loadWallets(t, p)
+	if err := wallets.ChangePassphrase("wrong", "new"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("changing with a wrong passphrase: got %v, want %v", err, ErrWrongPassphrase)
+	}
+	if err := wallets.ChangePassphrase("pw", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadWallets(t, p)
+	if err := loaded.Unlock("pw"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("the old passphrase still unlocks the wallet: %v", err)
+	}
+	if err := loaded.Unlock("new"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Wallets[address].PrivateKey, privateKey) {
+		t.Fatal("the new passphrase did not restore the private key")
+	}
+}
@@ -0,0 +1,246 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	HardenedOffset  uint32 = 0x80000000
+	ReceiveChain    uint32 = 0
+	ChangeChain     uint32 = 1
+	DefaultGapLimit        = 20
+)
+
+var (
+	masterKeySalt  = []byte("golang-blockchain seed")
+	ErrNoSeed      = errors.New("wallet has no HD seed")
+	ErrInvalidPath = errors.New("derived key is invalid for this index")
+)
+
+type ExtendedKey struct {
+	Key       *big.Int
+	ChainCode []byte
+}
+
+type HDChain struct {
+	Seed        []byte
+	Mnemonic    []byte
+	NextReceive uint32
+	NextChange  uint32
+	Paths       map[string]string
+}
+
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(elliptic.P256().Params().N) >= 0 {
+		return nil, ErrInvalidPath
+	}
+	return &ExtendedKey{Key: key, ChainCode: sum[32:]}, nil
+}
+
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	curve := elliptic.P256()
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0x00}, k.Key.FillBytes(make([]byte, 32))...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.Key.FillBytes(make([]byte, 32)))
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	n := curve.Params().N
+	if tweak.Cmp(n) >= 0 {
+		return nil, ErrInvalidPath
+	}
+	child := new(big.Int).Add(tweak, k.Key)
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, ErrInvalidPath
+	}
+	return &ExtendedKey{Key: child, ChainCode: sum[32:]}, nil
+}
+
+func (k *ExtendedKey) Wallet() *Wallet {
+	curve := elliptic.P256()
+	private := k.Key.FillBytes(make([]byte, privateKeyLength))
+	x, y := curve.ScalarBaseMult(private)
+
+	return &Wallet{Type: P256, PrivateKey: private, PublicKey: elliptic.MarshalCompressed(curve, x, y)}
+}
+
+func DeriveKey(seed []byte, path []uint32) (*ExtendedKey, error) {
+	key, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range path {
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func ChainPath(chain, index uint32) []uint32 {
+	return []uint32{HardenedOffset, chain, index}
+}
+
+func FormatPath(path []uint32) string {
+	parts := []string{"m"}
+	for _, index := range path {
+		if index >= HardenedOffset {
+			parts = append(parts, fmt.Sprintf("%d'", index-HardenedOffset))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", index))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (ws *Wallets) HasSeed() bool {
+	return ws.hd != nil
+}
+
+func (ws *Wallets) NewSeed(passphrase string) (string, error) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		return "", err
+	}
+	return mnemonic, ws.SetMnemonic(mnemonic, passphrase)
+}
+
+func (ws *Wallets) SetSeed(seed []byte) error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	if _, err := NewMasterKey(seed); err != nil {
+		return err
+	}
+	ws.hd = &HDChain{Seed: seed, Paths: make(map[string]string)}
+	return nil
+}
+
+func (ws *Wallets) derive(chain, index uint32) (*Wallet, string, error) {
+	if ws.hd == nil {
+		return nil, "", ErrNoSeed
+	}
+	if ws.hd.Seed == nil {
+		return nil, "", ErrWalletLocked
+	}
+
+	path := ChainPath(chain, index)
+	key, err := DeriveKey(ws.hd.Seed, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return key.Wallet(), FormatPath(path), nil
+}
+
+func (ws *Wallets) addDerived(wallet *Wallet, chain uint32, path string) string {
+	address := string(wallet.Address(ws.params.AddressVersion))
+	ws.Wallets[address] = wallet
+	if ws.hd.Paths == nil {
+		ws.hd.Paths = make(map[string]string)
+	}
+	ws.hd.Paths[address] = path
+	if chain == ChangeChain {
+		ws.addInfo(address, PurposeChange)
+	} else {
+		ws.addInfo(address, PurposeReceive)
+	}
+	return address
+}
+
+func (ws *Wallets) NextAddress(chain uint32) (string, error) {
+	if ws.hd == nil {
+		return "", ErrNoSeed
+	}
+
+	next := &ws.hd.NextReceive
+	if chain == ChangeChain {
+		next = &ws.hd.NextChange
+	}
+
+	for {
+		wallet, path, err := ws.derive(chain, *next)
+		if err != nil && err != ErrInvalidPath {
+			return "", err
+		}
+		*next++
+		if err == ErrInvalidPath {
+			continue
+		}
+		return ws.addDerived(wallet, chain, path), nil
+	}
+}
+
+func (ws *Wallets) Discover(used func(publicKeyHash []byte) bool, gapLimit int) ([]string, error) {
+	var found []string
+	if ws.hd == nil {
+		return nil, ErrNoSeed
+	}
+
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		next := &ws.hd.NextReceive
+		if chain == ChangeChain {
+			next = &ws.hd.NextChange
+		}
+
+		gap := 0
+		for index := uint32(0); gap < gapLimit; index++ {
+			wallet, path, err := ws.derive(chain, index)
+			if err == ErrInvalidPath {
+				continue
+			}
+			if err != nil {
+				return found, err
+			}
+			if !used(PublicKeyHash(wallet.PublicKey)) {
+				// Seeds used before SEC1 keys derived addresses from the
+				// legacy encoding, keep those addresses when restoring.
+				key, err := ParsePublicKey(wallet.PublicKey)
+				if err != nil {
+					return found, err
+				}
+				legacy := LegacyPublicKey(key)
+				if !used(PublicKeyHash(legacy)) {
+					gap++
+					continue
+				}
+				wallet.PublicKey = legacy
+			}
+
+			gap = 0
+			if index >= *next {
+				*next = index + 1
+			}
+			found = append(found, ws.addDerived(wallet, chain, path))
+		}
+	}
+	return found, nil
+}
+
+func (ws *Wallets) Path(address string) string {
+	if ws.hd == nil {
+		return ""
+	}
+	return ws.hd.Paths[address]
+}
@@ -0,0 +1,170 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testSeed = bytes.Repeat([]byte{0x42}, 32)
+
+func seededWallets(t *testing.T) *Wallets {
+	t.Helper()
+	wallets, _ := newTestWallets(t)
+	if err := wallets.SetSeed(testSeed); err != nil {
+		t.Fatal(err)
+	}
+	return wallets
+}
+
+func TestDeriveKeyIsDeterministic(t *testing.T) {
+	first, err := DeriveKey(testSeed, ChainPath(ReceiveChain, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := DeriveKey(testSeed, ChainPath(ReceiveChain, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Key.Cmp(again.Key) != 0 || !bytes.Equal(first.ChainCode, again.ChainCode) {
+		t.Fatal("the same seed and path derived different keys")
+	}
+
+	others := [][]uint32{
+		ChainPath(ReceiveChain, 1),
+		ChainPath(ChangeChain, 0),
+		{0, ReceiveChain, 0},
+	}
+	for _, path := range others {
+		key, err := DeriveKey(testSeed, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.Key.Cmp(first.Key) == 0 {
+			t.Errorf("%s derived the key of %s", FormatPath(path), FormatPath(ChainPath(ReceiveChain, 0)))
+		}
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	if got, want := FormatPath(ChainPath(ChangeChain, 7)), "m/0'/1/7"; got != want {
+		t.Fatalf("FormatPath() = %s, want %s", got, want)
+	}
+}
+
+func TestNextAddressAdvancesEachChain(t *testing.T) {
+	wallets := seededWallets(t)
+	var addresses []string
+	for _, chain := range []uint32{ReceiveChain, ReceiveChain, ChangeChain} {
+		address, err := wallets.NextAddress(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addresses = append(addresses, address)
+	}
+
+	for i, want := range []string{"m/0'/0/0", "m/0'/0/1", "m/0'/1/0"} {
+		if got := wallets.Path(addresses[i]); got != want {
+			t.Errorf("address %d has path %s, want %s", i, got, want)
+		}
+	}
+	if addresses[0] == addresses[1] || addresses[0] == addresses[2] {
+		t.Fatal("the chains handed out the same address twice")
+	}
+
+	restored := seededWallets(t)
+	for _, want := range addresses[:2] {
+		if got, _ := restored.NextAddress(ReceiveChain); got != want {
+			t.Fatalf("the same seed derived %s, want %s", got, want)
+		}
+	}
+}
+
+func TestNextAddressNeedsASeed(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	if _, err := wallets.NextAddress(ReceiveChain); err != ErrNoSeed {
+		t.Fatalf("NextAddress() without a seed: got %v, want %v", err, ErrNoSeed)
+	}
+}
+
+func TestDiscoverStopsAtTheGapLimit(t *testing.T) {
+	used := make(map[string]bool)
+	var want []string
+	for _, index := range []struct {
+		chain, index uint32
+		found        bool
+	}{
+		{ReceiveChain, 0, true},
+		{ReceiveChain, 3, true},
+		{ReceiveChain, 7, false},
+		{ChangeChain, 2, true},
+	} {
+		key, err := DeriveKey(testSeed, ChainPath(index.chain, index.index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallet := key.Wallet()
+		used[string(PublicKeyHash(wallet.PublicKey))] = true
+		if index.found {
+			want = append(want, FormatPath(ChainPath(index.chain, index.index)))
+		}
+	}
+
+	wallets := seededWallets(t)
+	found, err := wallets.Discover(func(publicKeyHash []byte) bool { return used[string(publicKeyHash)] }, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, address := range found {
+		paths = append(paths, wallets.Path(address))
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("discovered %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("discovered %v, want %v", paths, want)
+		}
+	}
+
+	next, err := wallets.NextAddress(ReceiveChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wallets.Path(next); got != "m/0'/0/4" {
+		t.Fatalf("after discovery the next receive address is %s, want m/0'/0/4", got)
+	}
+}
+
+func TestEncryptedSeedSurvivesASaveAndUnlock(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	if err := wallets.SetSeed(testSeed); err != nil {
+		t.Fatal(err)
+	}
+	first, err := wallets.NextAddress(ReceiveChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.Encrypt("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	checkNoKeysOnDisk(t, p, testSeed)
+
+	loaded := loadWallets(t, p)
+	if _, err := loaded.NextAddress(ReceiveChain); err != ErrWalletLocked {
+		t.Fatalf("deriving from a locked wallet: got %v, want %v", err, ErrWalletLocked)
+	}
+	if err := loaded.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := loaded.NextAddress(ReceiveChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || loaded.Path(second) != "m/0'/0/1" {
+		t.Fatalf("the reloaded wallet derived %s at %s", second, loaded.Path(second))
+	}
+}
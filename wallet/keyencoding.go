@@ -0,0 +1,155 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	CompressedKeyLength   = 33
+	UncompressedKeyLength = 65
+	SignatureLength       = 2 * coordinateSize
+)
+
+var (
+	ErrInvalidPublicKey = errors.New("public key is malformed")
+	ErrHighS            = errors.New("signature S value is not in the lower half of the curve order")
+)
+
+func EncodePublicKey(key *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+}
+
+// Keys created before SEC1 encoding were stored as X.Bytes() || Y.Bytes(),
+// which drops leading zero bytes. Their hashes still lock outputs, so the
+// point has to be able to reproduce that encoding.
+func LegacyPublicKey(key *ecdsa.PublicKey) []byte {
+	return append(key.X.Bytes(), key.Y.Bytes()...)
+}
+
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+
+	switch {
+	case len(data) == CompressedKeyLength && (data[0] == 0x02 || data[0] == 0x03):
+		x, y = elliptic.UnmarshalCompressed(curve, data)
+	case len(data) == UncompressedKeyLength && data[0] == 0x04:
+		x, y = elliptic.Unmarshal(curve, data)
+	default:
+		return nil, fmt.Errorf("%w: %d bytes is not a SEC1 encoding", ErrInvalidPublicKey, len(data))
+	}
+	if x == nil {
+		return nil, fmt.Errorf("%w: point is not on the curve", ErrInvalidPublicKey)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func ParseLegacyPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	if key, err := ParsePublicKey(data); err == nil {
+		return key, nil
+	}
+	if len(data) > 2*coordinateSize {
+		return nil, fmt.Errorf("%w: %d bytes is too long for a legacy key", ErrInvalidPublicKey, len(data))
+	}
+
+	// The split point is unknown when a coordinate lost a leading zero, so
+	// try every split that fits and keep the one that lands on the curve.
+	curve := elliptic.P256()
+	for split := len(data) - coordinateSize; split <= coordinateSize; split++ {
+		if split < 1 || split >= len(data) {
+			continue
+		}
+		x := new(big.Int).SetBytes(data[:split])
+		y := new(big.Int).SetBytes(data[split:])
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: point is not on the curve", ErrInvalidPublicKey)
+}
+
+func PublicKeyEncodings(key *ecdsa.PublicKey) [][]byte {
+	return [][]byte{
+		EncodePublicKey(key),
+		elliptic.Marshal(key.Curve, key.X, key.Y),
+		LegacyPublicKey(key),
+	}
+}
+
+func EncodeSignature(curve elliptic.Curve, r, s *big.Int) []byte {
+	n := curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+
+	signature := make([]byte, SignatureLength)
+	r.FillBytes(signature[:coordinateSize])
+	s.FillBytes(signature[coordinateSize:])
+	return signature
+}
+
+func ParseSignature(curve elliptic.Curve, signature []byte) (*big.Int, *big.Int, error) {
+	if len(signature) != SignatureLength {
+		return nil, nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidSignature, len(signature), SignatureLength)
+	}
+
+	n := curve.Params().N
+	r := new(big.Int).SetBytes(signature[:coordinateSize])
+	s := new(big.Int).SetBytes(signature[coordinateSize:])
+	if r.Sign() == 0 || r.Cmp(n) >= 0 || s.Sign() == 0 || s.Cmp(n) >= 0 {
+		return nil, nil, fmt.Errorf("%w: value out of range", ErrInvalidSignature)
+	}
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return nil, nil, ErrHighS
+	}
+	return r, s, nil
+}
+
+func ParseLegacySignature(curve elliptic.Curve, signature []byte) (*big.Int, *big.Int, error) {
+	if len(signature) == SignatureLength {
+		r := new(big.Int).SetBytes(signature[:coordinateSize])
+		s := new(big.Int).SetBytes(signature[coordinateSize:])
+		return r, s, nil
+	}
+	if len(signature) < 2 || len(signature) > SignatureLength {
+		return nil, nil, fmt.Errorf("%w: %d bytes", ErrInvalidSignature, len(signature))
+	}
+	half := len(signature) / 2
+	return new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:]), nil
+}
+
+func SignDigest(privateKey *ecdsa.PrivateKey, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeSignature(privateKey.Curve, r, s), nil
+}
+
+// VerifyDigest checks a signature over digest. Strict mode only accepts SEC1
+// keys and fixed-width low-S signatures, the lenient mode also reads the
+// encodings produced before they were enforced.
+func VerifyDigest(publicKey, digest, signature []byte, strict bool) error {
+	parseKey, parseSignature := ParsePublicKey, ParseSignature
+	if !strict {
+		parseKey, parseSignature = ParseLegacyPublicKey, ParseLegacySignature
+	}
+
+	key, err := parseKey(publicKey)
+	if err != nil {
+		return err
+	}
+	r, s, err := parseSignature(key.Curve, signature)
+	if err != nil {
+		return err
+	}
+	if !ecdsa.Verify(key, digest, r, s) {
+		return ErrMessageMismatch
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestPublicKeyEncodings(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, encoded := range PublicKeyEncodings(&key.PublicKey) {
+			parsed, err := ParseLegacyPublicKey(encoded)
+			if err != nil {
+				t.Fatalf("%x: %v", encoded, err)
+			}
+			if !parsed.Equal(&key.PublicKey) {
+				t.Fatalf("%x parses to another point", encoded)
+			}
+		}
+		if _, err := ParsePublicKey(LegacyPublicKey(&key.PublicKey)); !errors.Is(err, ErrInvalidPublicKey) {
+			t.Fatalf("strict parsing of a legacy key: got %v, want %v", err, ErrInvalidPublicKey)
+		}
+	}
+
+	offCurve := make([]byte, UncompressedKeyLength)
+	offCurve[0] = 0x04
+	offCurve[coordinateSize] = 1
+	offCurve[2*coordinateSize] = 1
+	if _, err := ParsePublicKey(offCurve); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Errorf("a point off the curve: got %v, want %v", err, ErrInvalidPublicKey)
+	}
+}
+
+// A legacy key whose X lost a leading zero byte still splits back.
+func TestParseLegacyKeyWithShortCoordinate(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key.X.Bytes()) == coordinateSize {
+			continue
+		}
+		parsed, err := ParseLegacyPublicKey(LegacyPublicKey(&key.PublicKey))
+		if err != nil || !parsed.Equal(&key.PublicKey) {
+			t.Fatalf("short legacy key did not parse back: %v", err)
+		}
+		return
+	}
+	t.Skip("no key with a short coordinate was generated")
+}
+
+func TestSignaturesAreFixedWidthAndLowS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := EncodePublicKey(&key.PublicKey)
+	digest := sha256.Sum256([]byte("message"))
+	half := new(big.Int).Rsh(elliptic.P256().Params().N, 1)
+
+	for i := 0; i < 50; i++ {
+		signature, err := SignDigest(key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(signature) != SignatureLength {
+			t.Fatalf("signature is %d bytes, want %d", len(signature), SignatureLength)
+		}
+		if new(big.Int).SetBytes(signature[coordinateSize:]).Cmp(half) > 0 {
+			t.Fatal("signature has a high S")
+		}
+		if err := VerifyDigest(publicKey, digest[:], signature, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStrictVerificationRejectsMalleatedSignatures(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("message"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := elliptic.P256().Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) <= 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+	highS := make([]byte, SignatureLength)
+	r.FillBytes(highS[:coordinateSize])
+	s.FillBytes(highS[coordinateSize:])
+
+	if err := VerifyDigest(EncodePublicKey(&key.PublicKey), digest[:], highS, true); !errors.Is(err, ErrHighS) {
+		t.Errorf("strict check of a high-S signature: got %v, want %v", err, ErrHighS)
+	}
+	if err := VerifyDigest(LegacyPublicKey(&key.PublicKey), digest[:], highS, false); err != nil {
+		t.Errorf("lenient check of a high-S signature: %v", err)
+	}
+
+	legacy := append(r.Bytes(), s.Bytes()...)
+	if err := VerifyDigest(EncodePublicKey(&key.PublicKey), digest[:], legacy[1:], true); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("strict check of a short signature: got %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,103 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/mr-tron/base58"
+)
+
+const (
+	privateKeyLength = 32
+	compressedFlag   = 0x01
+)
+
+var ErrInvalidPrivateKey = errors.New("invalid private key encoding")
+
+// Keys are encoded as version | secret | flag | key type. The flag marks a
+// compressed public key and the key type is only present for non-P256 keys.
+func EncodePrivateKey(w *Wallet, version byte) string {
+	secret := make([]byte, privateKeyLength-len(w.PrivateKey), privateKeyLength)
+	payload := append([]byte{version}, append(secret, w.PrivateKey...)...)
+	if w.Type != P256 {
+		payload = append(payload, compressedFlag, byte(w.Type))
+	} else if len(w.PublicKey) == CompressedKeyLength {
+		payload = append(payload, compressedFlag)
+	}
+	payload = append(payload, Checksum(payload)...)
+	return string(Base58Encode(payload))
+}
+
+func DecodePrivateKey(encoded string, version byte) (*Wallet, error) {
+	decoded, err := base58.Decode(encoded)
+	if err != nil {
+		return nil, ErrInvalidPrivateKey
+	}
+	extra := len(decoded) - (1 + privateKeyLength + checksumLength)
+	if extra < 0 || extra > 2 {
+		return nil, ErrInvalidPrivateKey
+	}
+
+	payload := decoded[:len(decoded)-checksumLength]
+	if !bytes.Equal(Checksum(payload), decoded[len(decoded)-checksumLength:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidPrivateKey)
+	}
+	if payload[0] != version {
+		return nil, fmt.Errorf("%w: key version 0x%02x belongs to another network", ErrInvalidPrivateKey, payload[0])
+	}
+	flags := payload[1+privateKeyLength:]
+	if extra > 0 && flags[0] != compressedFlag {
+		return nil, fmt.Errorf("%w: unknown key flag 0x%02x", ErrInvalidPrivateKey, flags[0])
+	}
+
+	w := &Wallet{Type: P256, PrivateKey: payload[1 : 1+privateKeyLength]}
+	if extra == 2 {
+		w.Type = KeyType(flags[1])
+	}
+	scheme, err := SchemeFor(w.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, err)
+	}
+	w.PublicKey, err = scheme.PublicKey(w.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if extra == 0 {
+		key, err := ParsePublicKey(w.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		w.PublicKey = LegacyPublicKey(key)
+	}
+	return w, nil
+}
+
+func (ws *Wallets) ExportKey(address string) (string, error) {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return "", fmt.Errorf("address %s is not in the wallet", address)
+	}
+	if w.PrivateKey == nil {
+		return "", ErrWalletLocked
+	}
+	return EncodePrivateKey(w, ws.params.PrivateKeyVersion), nil
+}
+
+func (ws *Wallets) ImportKey(encoded string) (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	w, err := DecodePrivateKey(encoded, ws.params.PrivateKeyVersion)
+	if err != nil {
+		return "", err
+	}
+	address := string(w.Address(ws.params.AddressVersion))
+	ws.Wallets[address] = w
+	delete(ws.WatchOnly, address)
+	ws.addInfo(address, PurposeImported)
+	ws.Metadata[address].Purpose = PurposeImported
+	return address, nil
+}
@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestExportImportKeyRoundTrip(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	address := wallets.AddWallet(P256)
+	encoded, err := wallets.ExportKey(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := newTestWallets(t)
+	imported, err := other.ImportKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != address {
+		t.Fatalf("the imported key has address %s, want %s", imported, address)
+	}
+	if !bytes.Equal(other.Wallets[address].PrivateKey, wallets.Wallets[address].PrivateKey) {
+		t.Fatal("the imported private key differs")
+	}
+	if again, _ := other.ExportKey(address); again != encoded {
+		t.Fatalf("exporting the imported key gave %s, want %s", again, encoded)
+	}
+
+	if _, err := wallets.ExportKey("unknown"); err == nil {
+		t.Error("exported the key of an address the wallet does not hold")
+	}
+}
+
+func TestDecodePrivateKeyRejectsBadKeys(t *testing.T) {
+	w := MakeWallet(P256)
+	encoded := EncodePrivateKey(w, params.RegTest.PrivateKeyVersion)
+
+	corrupted := []byte(encoded)
+	last := len(corrupted) - 1
+	if corrupted[last] == '2' {
+		corrupted[last] = '3'
+	} else {
+		corrupted[last] = '2'
+	}
+	if _, err := DecodePrivateKey(string(corrupted), params.RegTest.PrivateKeyVersion); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("a mistyped key: got %v, want %v", err, ErrInvalidPrivateKey)
+	}
+	if _, err := DecodePrivateKey(encoded[:len(encoded)-2], params.RegTest.PrivateKeyVersion); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("a truncated key: got %v, want %v", err, ErrInvalidPrivateKey)
+	}
+	if _, err := DecodePrivateKey("0"+encoded[1:], params.RegTest.PrivateKeyVersion); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("a key that is not Base58: got %v, want %v", err, ErrInvalidPrivateKey)
+	}
+	if _, err := DecodePrivateKey(encoded, params.MainNet.PrivateKeyVersion); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("a regtest key on mainnet: got %v, want %v", err, ErrInvalidPrivateKey)
+	}
+
+	payload := make([]byte, 1+privateKeyLength)
+	payload[0] = params.RegTest.PrivateKeyVersion
+	zero := string(Base58Encode(append(payload, Checksum(payload)...)))
+	if _, err := DecodePrivateKey(zero, params.RegTest.PrivateKeyVersion); !errors.Is(err, ErrInvalidPrivateKey) {
+		t.Errorf("a zero key: got %v, want %v", err, ErrInvalidPrivateKey)
+	}
+}
+
+func TestImportedKeyIsEncryptedWithTheWallet(t *testing.T) {
+	p, _, _ := newEncryptedWallets(t)
+	w := MakeWallet(P256)
+	encoded := EncodePrivateKey(w, p.PrivateKeyVersion)
+
+	locked := loadWallets(t, p)
+	if _, err := locked.ImportKey(encoded); err != ErrWalletLocked {
+		t.Fatalf("importing into a locked wallet: got %v, want %v", err, ErrWalletLocked)
+	}
+	if err := locked.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	address, err := locked.ImportKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := locked.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	checkNoKeysOnDisk(t, p, w.PrivateKey)
+
+	loaded := loadWallets(t, p)
+	if _, err := loaded.ExportKey(address); err != ErrWalletLocked {
+		t.Fatalf("exporting from a locked wallet: got %v, want %v", err, ErrWalletLocked)
+	}
+	if err := loaded.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if exported, _ := loaded.ExportKey(address); exported != encoded {
+		t.Fatalf("the reloaded wallet exported %s, want %s", exported, encoded)
+	}
+}
@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"golang-blockchain/params"
+)
+
+const (
+	messageMagic          = "Golang Blockchain Signed Message:\n"
+	coordinateSize        = 32
+	legacySignatureLength = 4 * coordinateSize
+)
+
+var (
+	ErrInvalidSignature = errors.New("signature is malformed")
+	ErrMessageMismatch  = errors.New("signature does not match the message")
+	ErrAddressMismatch  = errors.New("signature was made by a different address")
+)
+
+func MessageHash(message string) []byte {
+	var payload bytes.Buffer
+	payload.WriteString(messageMagic)
+	payload.Write(binary.AppendUvarint(nil, uint64(len(message))))
+	payload.WriteString(message)
+
+	first := sha256.Sum256(payload.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// Signatures are type | key length | public key | signature. Signatures
+// from before key types were X | Y | R | S of a P256 key and are still read.
+func SignMessage(w *Wallet, message string) (string, error) {
+	signature, err := w.Sign(MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+
+	encoded := append([]byte{byte(w.Type), byte(len(w.PublicKey))}, w.PublicKey...)
+	encoded = append(encoded, signature...)
+	return base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+func VerifyMessage(address, signature, message string, p *params.Params) error {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(raw) < 2 {
+		return ErrInvalidSignature
+	}
+
+	keyType, publicKey, rs := P256, []byte(nil), []byte(nil)
+	if len(raw) == legacySignatureLength {
+		curve := elliptic.P256()
+		x := new(big.Int).SetBytes(raw[:coordinateSize])
+		y := new(big.Int).SetBytes(raw[coordinateSize : 2*coordinateSize])
+		if !curve.IsOnCurve(x, y) {
+			return ErrInvalidSignature
+		}
+		publicKey = elliptic.MarshalCompressed(curve, x, y)
+		rs = raw[2*coordinateSize:]
+	} else {
+		keyType = KeyType(raw[0])
+		if len(raw) < 2+int(raw[1]) {
+			return ErrInvalidSignature
+		}
+		publicKey = raw[2 : 2+int(raw[1])]
+		rs = raw[2+int(raw[1]):]
+	}
+
+	scheme, err := SchemeFor(keyType)
+	if err != nil {
+		return err
+	}
+	addr, err := DecodeAddress(address, p)
+	if err != nil {
+		return err
+	}
+	if addr.Key != keyType || !MatchesPublicKey(addr.Hash, publicKey) {
+		return ErrAddressMismatch
+	}
+	return scheme.Verify(publicKey, MessageHash(message), rs, false)
+}
@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestSignAndVerifyMessage(t *testing.T) {
+	w := MakeWallet(P256)
+	address := string(w.Address(params.RegTest.AddressVersion))
+	signature, err := SignMessage(w, "pay the rent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMessage(address, signature, "pay the rent", &params.RegTest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyMessage(address, signature, "pay the rent twice", &params.RegTest); err != ErrMessageMismatch {
+		t.Errorf("an altered message: got %v, want %v", err, ErrMessageMismatch)
+	}
+	other := string(MakeWallet(P256).Address(params.RegTest.AddressVersion))
+	if err := VerifyMessage(other, signature, "pay the rent", &params.RegTest); err != ErrAddressMismatch {
+		t.Errorf("another address: got %v, want %v", err, ErrAddressMismatch)
+	}
+	mainnet := string(w.Address(params.MainNet.AddressVersion))
+	if err := VerifyMessage(mainnet, signature, "pay the rent", &params.MainNet); err != nil {
+		t.Errorf("the same key on mainnet: %v", err)
+	}
+	if err := VerifyMessage(mainnet, signature, "pay the rent", &params.RegTest); !errors.Is(err, ErrAddressNetwork) {
+		t.Errorf("a mainnet address on regtest: got %v, want %v", err, ErrAddressNetwork)
+	}
+	for _, bad := range []string{"", "0OIl", address[:len(address)-1]} {
+		if err := VerifyMessage(bad, signature, "pay the rent", &params.RegTest); err == nil {
+			t.Errorf("address %q verified", bad)
+		}
+	}
+}
+
+func TestVerifyMessageRejectsMalformedSignatures(t *testing.T) {
+	w := MakeWallet(P256)
+	address := string(w.Address(params.RegTest.AddressVersion))
+	signature, err := SignMessage(w, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(signature)
+
+	longKey := append([]byte{}, raw...)
+	longKey[1] = 0xff
+	tests := map[string]string{
+		"not base64":           "%%%",
+		"too short":            base64.StdEncoding.EncodeToString(raw[:1]),
+		"key past the end":     base64.StdEncoding.EncodeToString(longKey),
+		"legacy key off curve": base64.StdEncoding.EncodeToString(make([]byte, legacySignatureLength)),
+	}
+	for name, signature := range tests {
+		if err := VerifyMessage(address, signature, "hello", &params.RegTest); err != ErrInvalidSignature {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidSignature)
+		}
+	}
+}
+
+func TestVerifyLegacyMessageSignature(t *testing.T) {
+	w := MakeWallet(P256)
+	address := string(w.Address(params.RegTest.AddressVersion))
+	key, err := ParsePublicKey(w.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := w.Sign(MessageHash("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := make([]byte, 2*coordinateSize)
+	key.X.FillBytes(legacy[:coordinateSize])
+	key.Y.FillBytes(legacy[coordinateSize:])
+	legacy = append(legacy, rs...)
+	if err := VerifyMessage(address, base64.StdEncoding.EncodeToString(legacy), "hello", &params.RegTest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessageSignaturesForEveryKeyType(t *testing.T) {
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		w := MakeWallet(keyType)
+		address := string(w.Address(params.RegTest.AddressVersion))
+		signature, err := SignMessage(w, "hello")
+		if err != nil {
+			t.Fatalf("%s: %v", keyType, err)
+		}
+		if err := VerifyMessage(address, signature, "hello", &params.RegTest); err != nil {
+			t.Errorf("%s: %v", keyType, err)
+		}
+		other := string(MakeWallet(keyType).Address(params.RegTest.AddressVersion))
+		if err := VerifyMessage(other, signature, "hello", &params.RegTest); err != ErrAddressMismatch {
+			t.Errorf("%s for another address: got %v, want %v", keyType, err, ErrAddressMismatch)
+		}
+	}
+}
+
+func TestSignMessageNeedsTheKey(t *testing.T) {
+	locked := &Wallet{PublicKey: MakeWallet(P256).PublicKey}
+	if _, err := SignMessage(locked, "hello"); err != ErrWalletLocked {
+		t.Fatalf("signing without a private key: got %v, want %v", err, ErrWalletLocked)
+	}
+}
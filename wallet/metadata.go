@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	PurposeReceive   = "receive"
+	PurposeChange    = "change"
+	PurposeImported  = "imported"
+	PurposeWatchOnly = "watch-only"
+)
+
+type AddressInfo struct {
+	Label   string
+	Created int64
+	Purpose string
+}
+
+func (ws *Wallets) addInfo(address, purpose string) {
+	if _, ok := ws.Metadata[address]; ok {
+		return
+	}
+	ws.Metadata[address] = &AddressInfo{Created: time.Now().Unix(), Purpose: purpose}
+}
+
+func (ws *Wallets) Info(address string) AddressInfo {
+	if info, ok := ws.Metadata[address]; ok {
+		return *info
+	}
+	return AddressInfo{}
+}
+
+func (ws *Wallets) SetLabel(address, label string) error {
+	info, ok := ws.Metadata[address]
+	if !ok {
+		return fmt.Errorf("address %s is not in the wallet", address)
+	}
+	info.Label = label
+	return nil
+}
+
+// Older files carry no metadata, so entries are filled in from what the
+// wallet already knows about each address.
+func (ws *Wallets) migrateMetadata() {
+	changePrefix := FormatPath([]uint32{HardenedOffset, ChangeChain}) + "/"
+	for address := range ws.Wallets {
+		purpose := PurposeReceive
+		if strings.HasPrefix(ws.Path(address), changePrefix) {
+			purpose = PurposeChange
+		}
+		if _, ok := ws.Metadata[address]; !ok {
+			ws.Metadata[address] = &AddressInfo{Purpose: purpose}
+		}
+	}
+	for address := range ws.WatchOnly {
+		if _, ok := ws.Metadata[address]; !ok {
+			ws.Metadata[address] = &AddressInfo{Purpose: PurposeWatchOnly}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestAddressPurposes(t *testing.T) {
+	wallets := seededWallets(t)
+	p := wallets.params
+	receive, _ := wallets.NextAddress(ReceiveChain)
+	change, _ := wallets.NextAddress(ChangeChain)
+	plain := wallets.AddWallet(P256)
+	imported, err := wallets.ImportKey(EncodePrivateKey(MakeWallet(P256), p.PrivateKeyVersion))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := string(MakeWallet(P256).Address(p.AddressVersion))
+	if err := wallets.AddWatchOnly(watched, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		receive:  PurposeReceive,
+		change:   PurposeChange,
+		plain:    PurposeReceive,
+		imported: PurposeImported,
+		watched:  PurposeWatchOnly,
+	}
+	for address, purpose := range want {
+		info := wallets.Info(address)
+		if info.Purpose != purpose {
+			t.Errorf("%s has purpose %q, want %q", address, info.Purpose, purpose)
+		}
+		if info.Created == 0 {
+			t.Errorf("%s has no creation time", address)
+		}
+	}
+}
+
+func TestLabelsArePersisted(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	address := wallets.AddWallet(P256)
+	if err := wallets.SetLabel(address, "savings"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SetLabel("unknown", "nothing"); err == nil {
+		t.Error("labelled an address that is not in the wallet")
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	info := loadWallets(t, p).Info(address)
+	if info.Label != "savings" || info.Purpose != PurposeReceive {
+		t.Fatalf("the reloaded address info is %+v", info)
+	}
+}
+
+func TestOlderFilesGetMetadata(t *testing.T) {
+	_, p := newTestWallets(t)
+	receive, receiveKey := legacyWallet(t, p)
+	watched, watchedKey := legacyWallet(t, p)
+	key, err := DeriveKey(testSeed, ChainPath(ChangeChain, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived := key.Wallet()
+	hash := sha256.Sum256(derived.PublicKey)
+	change := (&Address{Type: PubKeyHashAddress, Hash: hash[:]}).Encode(p)
+
+	// Files from before metadata existed had no header either.
+	var older bytes.Buffer
+	err = gob.NewEncoder(&older).Encode(walletData{
+		Wallets:   map[string]SerializableWallet{receive: receiveKey, change: derived.ToSerializable()},
+		WatchOnly: map[string][]byte{watched: watchedKey.PublicKey},
+		HD:        &HDChain{Seed: testSeed, NextChange: 1, Paths: map[string]string{change: "m/0'/1/0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.WalletPath(), older.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadWallets(t, p)
+	want := map[string]string{change: PurposeChange, receive: PurposeReceive, watched: PurposeWatchOnly}
+	for address, purpose := range want {
+		if info := loaded.Info(address); info.Purpose != purpose || info.Created != 0 {
+			t.Errorf("%s migrated to %+v, want purpose %q and no creation time", address, info, purpose)
+		}
+	}
+}
+
+func TestLegacyWalletFileLoads(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "tmp", "wallets.data"))
+	if err != nil {
+		t.Skip(err)
+	}
+	p := params.MainNet
+	p.SetDataDir(t.TempDir())
+	if err := os.WriteFile(p.WalletPath(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	wallets := loadWallets(t, &p)
+	if len(wallets.Wallets) == 0 {
+		t.Fatal("the legacy wallet file holds no addresses")
+	}
+	for address, w := range wallets.Wallets {
+		if w.PrivateKey == nil {
+			t.Errorf("%s lost its private key", address)
+		}
+		if info := wallets.Info(address); info.Purpose != PurposeReceive {
+			t.Errorf("%s migrated to %+v", address, info)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+const mnemonicEntropyBits = 256
+
+var ErrNoMnemonic = errors.New("wallet seed was not created from a mnemonic")
+
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+func NormalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
+
+func ValidateMnemonic(mnemonic string) error {
+	words := strings.Fields(NormalizeMnemonic(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return fmt.Errorf("mnemonic has %d words, expected 12, 15, 18, 21 or 24", len(words))
+	}
+
+	for i, word := range words {
+		if _, ok := bip39.GetWordIndex(word); !ok {
+			return fmt.Errorf("word %d (%q) is not in the word list", i+1, word)
+		}
+	}
+	if _, err := bip39.EntropyFromMnemonic(strings.Join(words, " ")); err != nil {
+		return fmt.Errorf("mnemonic checksum does not match, check the words and their order")
+	}
+	return nil
+}
+
+func (ws *Wallets) SetMnemonic(mnemonic, passphrase string) error {
+	mnemonic = NormalizeMnemonic(mnemonic)
+	err := ValidateMnemonic(mnemonic)
+	if err != nil {
+		return err
+	}
+
+	err = ws.SetSeed(bip39.NewSeed(mnemonic, passphrase))
+	if err != nil {
+		return err
+	}
+	ws.hd.Mnemonic = []byte(mnemonic)
+	return nil
+}
+
+func (ws *Wallets) Mnemonic() (string, error) {
+	if ws.hd == nil {
+		return "", ErrNoSeed
+	}
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+	if ws.hd.Mnemonic == nil {
+		return "", ErrNoMnemonic
+	}
+	return string(ws.hd.Mnemonic), nil
+}
@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestSetMnemonicMatchesBIP39(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	if err := wallets.SetMnemonic(testMnemonic, "TREZOR"); err != nil {
+		t.Fatal(err)
+	}
+	want := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(wallets.hd.Seed); got != want {
+		t.Fatalf("seed = %s, want %s", got, want)
+	}
+}
+
+func TestMnemonicRestoresTheSameAddresses(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	mnemonic, err := wallets.NewSeed("extra words")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words := len(strings.Fields(mnemonic)); words != 24 {
+		t.Fatalf("a new mnemonic has %d words", words)
+	}
+	if got, err := wallets.Mnemonic(); err != nil || got != mnemonic {
+		t.Fatalf("Mnemonic() = %q, %v", got, err)
+	}
+	address, err := wallets.NextAddress(ReceiveChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := newTestWallets(t)
+	if err := restored.SetMnemonic("  "+strings.ToUpper(mnemonic)+"\n", "extra words"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restored.NextAddress(ReceiveChain); got != address {
+		t.Fatalf("the restored wallet derived %s, want %s", got, address)
+	}
+
+	other, _ := newTestWallets(t)
+	if err := other.SetMnemonic(mnemonic, "other words"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := other.NextAddress(ReceiveChain); got == address {
+		t.Fatal("a different passphrase derived the same address")
+	}
+}
+
+func TestValidateMnemonicRejectsBadPhrases(t *testing.T) {
+	tests := map[string]string{
+		"bad checksum":    strings.Repeat("abandon ", 12),
+		"swapped words":   "about abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"unknown word":    strings.Replace(testMnemonic, "about", "aboot", 1),
+		"too few words":   "abandon abandon abandon about",
+		"no words at all": "",
+	}
+	for name, mnemonic := range tests {
+		if err := ValidateMnemonic(mnemonic); err == nil {
+			t.Errorf("%s: ValidateMnemonic() accepted %q", name, mnemonic)
+		}
+	}
+	if err := ValidateMnemonic(testMnemonic); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptedMnemonicNeedsTheKey(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	if err := wallets.SetMnemonic(testMnemonic, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.Encrypt("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	checkNoKeysOnDisk(t, p, []byte(testMnemonic))
+
+	loaded := loadWallets(t, p)
+	if _, err := loaded.Mnemonic(); err != ErrWalletLocked {
+		t.Fatalf("reading the mnemonic of a locked wallet: got %v, want %v", err, ErrWalletLocked)
+	}
+	if err := loaded.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := loaded.Mnemonic(); err != nil || got != testMnemonic {
+		t.Fatalf("Mnemonic() = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,417 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// MuSig lets N parties holding Schnorr keys produce one signature for an
+// aggregated key P = sum(a_i*P_i). Signing takes two rounds: every party
+// publishes two nonce points per message, then every party publishes a
+// partial signature. The session travels between the parties as a file.
+
+const nonceLength = 2 * CompressedKeyLength
+
+var (
+	ErrNotParticipant   = errors.New("key is not a participant of this session")
+	ErrMissingNonces    = errors.New("not every participant has added nonces yet")
+	ErrMissingPartials  = errors.New("not every participant has signed yet")
+	ErrNonceUsed        = errors.New("no secret nonces for this session, they were used or never made here")
+	ErrDuplicateKey     = errors.New("the same key appears twice")
+	ErrAlreadyCommitted = errors.New("this participant already added nonces")
+)
+
+type MuSigSession struct {
+	ID         []byte
+	PublicKeys [][]byte
+	Messages   [][]byte
+	Nonces     map[string][][]byte
+	Partials   map[string][][]byte
+	Payload    []byte
+}
+
+func sortKeys(keys [][]byte) ([][]byte, error) {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+
+	for i, key := range sorted {
+		if _, err := parsePoint(key); err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Equal(key, sorted[i-1]) {
+			return nil, ErrDuplicateKey
+		}
+	}
+	return sorted, nil
+}
+
+func keyCoefficient(keys [][]byte, key []byte) *secp256k1.ModNScalar {
+	list := taggedHash("golang-blockchain/keyagg-list", keys...)
+	return hashToScalar("golang-blockchain/keyagg-coef", list, key)
+}
+
+func AggregateKeys(keys [][]byte) ([]byte, error) {
+	if len(keys) < 2 {
+		return nil, fmt.Errorf("%w: aggregation needs at least two keys", ErrInvalidPublicKey)
+	}
+	sorted, err := sortKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregate secp256k1.JacobianPoint
+	for _, key := range sorted {
+		point, _ := parsePoint(key)
+		var weighted secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(keyCoefficient(sorted, key), point, &weighted)
+		addPoints(&aggregate, &weighted)
+	}
+	return encodePoint(&aggregate)
+}
+
+func NewMuSigSession(keys [][]byte, messages [][]byte, payload []byte) (*MuSigSession, error) {
+	sorted, err := sortKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	idBytes := id.Bytes()
+
+	return &MuSigSession{
+		ID:         idBytes[:],
+		PublicKeys: sorted,
+		Messages:   messages,
+		Nonces:     make(map[string][][]byte),
+		Partials:   make(map[string][][]byte),
+		Payload:    payload,
+	}, nil
+}
+
+func ReadMuSigSession(path string) (*MuSigSession, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var session MuSigSession
+	err = gob.NewDecoder(bytes.NewReader(content)).Decode(&session)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a signing session: %s", path, err)
+	}
+	if session.Nonces == nil {
+		session.Nonces = make(map[string][][]byte)
+	}
+	if session.Partials == nil {
+		session.Partials = make(map[string][][]byte)
+	}
+	return &session, nil
+}
+
+func (s *MuSigSession) WriteFile(path string) error {
+	var content bytes.Buffer
+	err := gob.NewEncoder(&content).Encode(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content.Bytes(), 0644)
+}
+
+func (s *MuSigSession) AggregateKey() ([]byte, error) {
+	return AggregateKeys(s.PublicKeys)
+}
+
+func (s *MuSigSession) hasKey(key []byte) bool {
+	for _, k := range s.PublicKeys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MuSigSession) Missing(round map[string][][]byte) []string {
+	var missing []string
+	for _, key := range s.PublicKeys {
+		if _, ok := round[hex.EncodeToString(key)]; !ok {
+			missing = append(missing, hex.EncodeToString(key))
+		}
+	}
+	return missing
+}
+
+// aggregateNonce returns R = R1 + b*R2 for one message, where R1 and R2 sum
+// the first and second nonce points of every party.
+func (s *MuSigSession) aggregateNonce(index int, aggregateKey []byte) (*secp256k1.JacobianPoint, *secp256k1.ModNScalar, error) {
+	if len(s.Missing(s.Nonces)) > 0 {
+		return nil, nil, ErrMissingNonces
+	}
+
+	var r1, r2 secp256k1.JacobianPoint
+	for _, key := range s.PublicKeys {
+		nonces := s.Nonces[hex.EncodeToString(key)]
+		if len(nonces) != len(s.Messages) {
+			return nil, nil, fmt.Errorf("%w: wrong number of nonces", ErrInvalidSignature)
+		}
+		first, second, err := parseNoncePair(nonces[index])
+		if err != nil {
+			return nil, nil, err
+		}
+		addPoints(&r1, first)
+		addPoints(&r2, second)
+	}
+	encoded1, err := encodePoint(&r1)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded2, err := encodePoint(&r2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := hashToScalar("golang-blockchain/musig-nonce", aggregateKey, encoded1, encoded2, s.Messages[index])
+	var weighted secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(b, &r2, &weighted)
+	addPoints(&r1, &weighted)
+	return &r1, b, nil
+}
+
+func parseNoncePair(pair []byte) (*secp256k1.JacobianPoint, *secp256k1.JacobianPoint, error) {
+	if len(pair) != nonceLength {
+		return nil, nil, fmt.Errorf("%w: nonce is %d bytes", ErrInvalidSignature, len(pair))
+	}
+	first, err := parsePoint(pair[:CompressedKeyLength])
+	if err != nil {
+		return nil, nil, err
+	}
+	second, err := parsePoint(pair[CompressedKeyLength:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return first, second, nil
+}
+
+func (ws *Wallets) musigWallet(s *MuSigSession, address string) (*Wallet, error) {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return nil, fmt.Errorf("address %s is not in the wallet", address)
+	}
+	if w.Type != Schnorr {
+		return nil, fmt.Errorf("address %s holds a %s key, MuSig needs a schnorr key", address, w.Type)
+	}
+	if !s.hasKey(w.PublicKey) {
+		return nil, ErrNotParticipant
+	}
+	return w, nil
+}
+
+func nonceKey(s *MuSigSession, publicKey []byte) string {
+	return hex.EncodeToString(s.ID) + ":" + hex.EncodeToString(publicKey)
+}
+
+// AddNonces makes fresh nonces for every message. The secret halves stay
+// in the wallet file until SignSession uses them once.
+func (ws *Wallets) AddNonces(s *MuSigSession, address string) error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	w, err := ws.musigWallet(s, address)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.Nonces[hex.EncodeToString(w.PublicKey)]; ok {
+		return ErrAlreadyCommitted
+	}
+
+	var secrets []byte
+	var public [][]byte
+	for range s.Messages {
+		pair := make([]byte, 0, nonceLength)
+		for i := 0; i < 2; i++ {
+			k, err := randomScalar()
+			if err != nil {
+				return err
+			}
+			var point secp256k1.JacobianPoint
+			secp256k1.ScalarBaseMultNonConst(k, &point)
+			encoded, err := encodePoint(&point)
+			if err != nil {
+				return err
+			}
+			kBytes := k.Bytes()
+			secrets = append(secrets, kBytes[:]...)
+			pair = append(pair, encoded...)
+		}
+		public = append(public, pair)
+	}
+
+	if ws.key != nil {
+		secrets, err = seal(ws.key, secrets)
+		if err != nil {
+			return err
+		}
+	}
+	ws.Nonces[nonceKey(s, w.PublicKey)] = secrets
+	s.Nonces[hex.EncodeToString(w.PublicKey)] = public
+	return nil
+}
+
+func (ws *Wallets) takeNonces(s *MuSigSession, publicKey []byte) ([]byte, error) {
+	key := nonceKey(s, publicKey)
+	secrets, ok := ws.Nonces[key]
+	if !ok {
+		return nil, ErrNonceUsed
+	}
+	delete(ws.Nonces, key)
+
+	if ws.encryption != nil {
+		var err error
+		secrets, err = unseal(ws.key, secrets)
+		if err != nil {
+			return nil, ErrCorruptedEncrypted
+		}
+	}
+	if len(secrets) != 2*coordinateSize*len(s.Messages) {
+		return nil, ErrNonceUsed
+	}
+	return secrets, nil
+}
+
+// SignSession adds the partial signatures s_i = k1 + b*k2 + e*a_i*x_i. The
+// secret nonces are deleted first, so a session can never be signed twice.
+func (ws *Wallets) SignSession(s *MuSigSession, address string) error {
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+	w, err := ws.musigWallet(s, address)
+	if err != nil {
+		return err
+	}
+	aggregateKey, err := s.AggregateKey()
+	if err != nil {
+		return err
+	}
+	if len(s.Missing(s.Nonces)) > 0 {
+		return ErrMissingNonces
+	}
+	secrets, err := ws.takeNonces(s, w.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	var x secp256k1.ModNScalar
+	if x.SetByteSlice(w.PrivateKey) || x.IsZero() {
+		return ErrInvalidPrivateKey
+	}
+	coefficient := keyCoefficient(s.PublicKeys, w.PublicKey)
+
+	var partials [][]byte
+	for i, message := range s.Messages {
+		r, b, err := s.aggregateNonce(i, aggregateKey)
+		if err != nil {
+			return err
+		}
+		encodedR, err := encodePoint(r)
+		if err != nil {
+			return err
+		}
+		e := schnorrChallenge(encodedR, aggregateKey, message)
+
+		var k1, k2 secp256k1.ModNScalar
+		offset := i * 2 * coordinateSize
+		k1.SetByteSlice(secrets[offset : offset+coordinateSize])
+		k2.SetByteSlice(secrets[offset+coordinateSize : offset+2*coordinateSize])
+
+		partial := new(secp256k1.ModNScalar).Mul2(e, coefficient).Mul(&x)
+		partial.Add(k2.Mul(b)).Add(&k1)
+		partialBytes := partial.Bytes()
+		partials = append(partials, partialBytes[:])
+	}
+	s.Partials[hex.EncodeToString(w.PublicKey)] = partials
+	return nil
+}
+
+// Signatures sums the partial signatures into one signature per message.
+// Every partial is checked against its own key and nonces so a bad
+// participant is named rather than just failing the final signature.
+func (s *MuSigSession) Signatures() ([][]byte, error) {
+	if len(s.Missing(s.Partials)) > 0 {
+		return nil, ErrMissingPartials
+	}
+	aggregateKey, err := s.AggregateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var signatures [][]byte
+	for i, message := range s.Messages {
+		r, b, err := s.aggregateNonce(i, aggregateKey)
+		if err != nil {
+			return nil, err
+		}
+		encodedR, err := encodePoint(r)
+		if err != nil {
+			return nil, err
+		}
+		e := schnorrChallenge(encodedR, aggregateKey, message)
+
+		var sum secp256k1.ModNScalar
+		for _, key := range s.PublicKeys {
+			name := hex.EncodeToString(key)
+			partials := s.Partials[name]
+			if len(partials) != len(s.Messages) {
+				return nil, fmt.Errorf("%w: wrong number of partial signatures from %s", ErrInvalidSignature, name)
+			}
+			var partial secp256k1.ModNScalar
+			if len(partials[i]) != coordinateSize || partial.SetByteSlice(partials[i]) {
+				return nil, fmt.Errorf("%w: partial signature from %s", ErrInvalidSignature, name)
+			}
+			if !s.checkPartial(i, key, &partial, b, e) {
+				return nil, fmt.Errorf("%w: partial signature from %s does not verify", ErrInvalidSignature, name)
+			}
+			sum.Add(&partial)
+		}
+
+		sumBytes := sum.Bytes()
+		signature := append(encodedR, sumBytes[:]...)
+		err = schnorrScheme{}.Verify(aggregateKey, message, signature, true)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// checkPartial tests s_i*G == R1_i + b*R2_i + e*a_i*P_i.
+func (s *MuSigSession) checkPartial(index int, key []byte, partial, b, e *secp256k1.ModNScalar) bool {
+	first, second, err := parseNoncePair(s.Nonces[hex.EncodeToString(key)][index])
+	if err != nil {
+		return false
+	}
+	point, err := parsePoint(key)
+	if err != nil {
+		return false
+	}
+
+	expected := *first
+	var weighted secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(b, second, &weighted)
+	addPoints(&expected, &weighted)
+	secp256k1.ScalarMultNonConst(new(secp256k1.ModNScalar).Mul2(e, keyCoefficient(s.PublicKeys, key)), point, &weighted)
+	addPoints(&expected, &weighted)
+
+	var actual secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(partial, &actual)
+	return equalPoints(&actual, &expected)
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type musigParty struct {
+	wallets *Wallets
+	address string
+	key     []byte
+}
+
+func newMuSigParty(t *testing.T) musigParty {
+	t.Helper()
+	wallets, _ := newTestWallets(t)
+	address := wallets.AddWallet(Schnorr)
+	return musigParty{wallets, address, wallets.Wallets[address].PublicKey}
+}
+
+// newTwoPartySession sets up a session over two messages with both parties'
+// nonces added.
+func newTwoPartySession(t *testing.T) (*MuSigSession, musigParty, musigParty) {
+	t.Helper()
+	alice, bob := newMuSigParty(t), newMuSigParty(t)
+	first, second := sha256.Sum256([]byte("first")), sha256.Sum256([]byte("second"))
+	session, err := NewMuSigSession([][]byte{alice.key, bob.key}, [][]byte{first[:], second[:]}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, party := range []musigParty{alice, bob} {
+		if err := party.wallets.AddNonces(session, party.address); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return session, alice, bob
+}
+
+func TestMuSigTwoParties(t *testing.T) {
+	session, alice, bob := newTwoPartySession(t)
+	if _, err := session.Signatures(); !errors.Is(err, ErrMissingPartials) {
+		t.Fatalf("signatures before anyone signed: got %v, want %v", err, ErrMissingPartials)
+	}
+	for _, party := range []musigParty{alice, bob} {
+		if err := party.wallets.SignSession(session, party.address); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signatures, err := session.Signatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregateKey, err := session.AggregateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != len(session.Messages) {
+		t.Fatalf("got %d signatures for %d messages", len(signatures), len(session.Messages))
+	}
+	for i, signature := range signatures {
+		if err := (schnorrScheme{}).Verify(aggregateKey, session.Messages[i], signature, true); err != nil {
+			t.Errorf("signature %d does not verify against the aggregate key: %v", i, err)
+		}
+	}
+	if err := (schnorrScheme{}).Verify(alice.key, session.Messages[0], signatures[0], true); err == nil {
+		t.Error("the aggregate signature verifies against a single party's key")
+	}
+}
+
+func TestMuSigNamesBadPartial(t *testing.T) {
+	session, alice, bob := newTwoPartySession(t)
+	for _, party := range []musigParty{alice, bob} {
+		if err := party.wallets.SignSession(session, party.address); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name := hex.EncodeToString(bob.key)
+	partial := append([]byte{}, session.Partials[name][1]...)
+	partial[len(partial)-1] ^= 1
+	session.Partials[name][1] = partial
+
+	_, err := session.Signatures()
+	if !errors.Is(err, ErrInvalidSignature) || !strings.Contains(err.Error(), name) {
+		t.Fatalf("a tampered partial from %s: got %v", name, err)
+	}
+}
+
+func TestMuSigNoncesAreUsedOnce(t *testing.T) {
+	session, alice, _ := newTwoPartySession(t)
+	if err := alice.wallets.AddNonces(session, alice.address); !errors.Is(err, ErrAlreadyCommitted) {
+		t.Fatalf("adding nonces twice: got %v, want %v", err, ErrAlreadyCommitted)
+	}
+
+	if err := alice.wallets.SignSession(session, alice.address); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.wallets.SignSession(session, alice.address); !errors.Is(err, ErrNonceUsed) {
+		t.Fatalf("signing a session twice: got %v, want %v", err, ErrNonceUsed)
+	}
+
+	// The secret nonces are gone from the wallet file as well.
+	if err := alice.wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loadTestWallets(alice.wallets.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.SignSession(session, alice.address); !errors.Is(err, ErrNonceUsed) {
+		t.Fatalf("signing again after a reload: got %v, want %v", err, ErrNonceUsed)
+	}
+}
+
+func TestMuSigRejectsOutsiders(t *testing.T) {
+	session, _, _ := newTwoPartySession(t)
+	outsider := newMuSigParty(t)
+	if err := outsider.wallets.AddNonces(session, outsider.address); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("an outsider adding nonces: got %v, want %v", err, ErrNotParticipant)
+	}
+	if _, err := NewMuSigSession([][]byte{outsider.key, outsider.key}, nil, nil); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("a session with the same key twice: got %v, want %v", err, ErrDuplicateKey)
+	}
+}
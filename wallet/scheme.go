@@ -0,0 +1,215 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+type KeyType byte
+
+const (
+	P256 KeyType = iota
+	Secp256k1
+	Ed25519
+	Schnorr
+)
+
+var ErrUnknownKeyType = errors.New("unknown key type")
+
+// A Scheme is one way of making and checking signatures. Outputs record the
+// key type they are locked to and verification dispatches on it.
+type Scheme interface {
+	NewKey() (private, public []byte, err error)
+	PublicKey(private []byte) ([]byte, error)
+	Sign(private, digest []byte) ([]byte, error)
+	Verify(public, digest, signature []byte, strict bool) error
+}
+
+var schemes = map[KeyType]Scheme{
+	P256:      p256Scheme{},
+	Secp256k1: secp256k1Scheme{},
+	Ed25519:   ed25519Scheme{},
+	Schnorr:   schnorrScheme{},
+}
+
+func (t KeyType) String() string {
+	switch t {
+	case P256:
+		return "p256"
+	case Secp256k1:
+		return "secp256k1"
+	case Ed25519:
+		return "ed25519"
+	case Schnorr:
+		return "schnorr"
+	}
+	return fmt.Sprintf("type%d", byte(t))
+}
+
+func ParseKeyType(name string) (KeyType, error) {
+	for t := range schemes {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q, expected p256, secp256k1, ed25519 or schnorr", ErrUnknownKeyType, name)
+}
+
+func SchemeFor(t KeyType) (Scheme, error) {
+	scheme, ok := schemes[t]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKeyType, byte(t))
+	}
+	return scheme, nil
+}
+
+type p256Scheme struct{}
+
+func (p256Scheme) privateKey(private []byte) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(private)
+	if len(private) > privateKeyLength || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("%w: key is out of range", ErrInvalidPrivateKey)
+	}
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, privateKeyLength)))
+	return key, nil
+}
+
+func (p256Scheme) NewKey() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.D.FillBytes(make([]byte, privateKeyLength)), EncodePublicKey(&key.PublicKey), nil
+}
+
+func (s p256Scheme) PublicKey(private []byte) ([]byte, error) {
+	key, err := s.privateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePublicKey(&key.PublicKey), nil
+}
+
+func (s p256Scheme) Sign(private, digest []byte) ([]byte, error) {
+	key, err := s.privateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return SignDigest(key, digest)
+}
+
+func (p256Scheme) Verify(public, digest, signature []byte, strict bool) error {
+	return VerifyDigest(public, digest, signature, strict)
+}
+
+// secp256k1 and Ed25519 keys never had a legacy encoding, so they are
+// always held to the strict rules.
+type secp256k1Scheme struct{}
+
+func (secp256k1Scheme) privateKey(private []byte) (*secp256k1.PrivateKey, error) {
+	var d secp256k1.ModNScalar
+	if len(private) != privateKeyLength || d.SetByteSlice(private) || d.IsZero() {
+		return nil, fmt.Errorf("%w: key is out of range", ErrInvalidPrivateKey)
+	}
+	return secp256k1.NewPrivateKey(&d), nil
+}
+
+func (secp256k1Scheme) NewKey() ([]byte, []byte, error) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	return key.Serialize(), key.PubKey().SerializeCompressed(), nil
+}
+
+func (s secp256k1Scheme) PublicKey(private []byte) ([]byte, error) {
+	key, err := s.privateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return key.PubKey().SerializeCompressed(), nil
+}
+
+func (s secp256k1Scheme) Sign(private, digest []byte) ([]byte, error) {
+	key, err := s.privateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	// The compact form is a recovery byte followed by R and S, with S
+	// already normalised to the lower half of the order.
+	return secpecdsa.SignCompact(key, digest, true)[1:], nil
+}
+
+func (secp256k1Scheme) Verify(public, digest, signature []byte, strict bool) error {
+	if len(public) != CompressedKeyLength {
+		return fmt.Errorf("%w: %d bytes is not a compressed secp256k1 key", ErrInvalidPublicKey, len(public))
+	}
+	key, err := secp256k1.ParsePubKey(public)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPublicKey, err)
+	}
+	if len(signature) != SignatureLength {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidSignature, len(signature), SignatureLength)
+	}
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:coordinateSize]) || s.SetByteSlice(signature[coordinateSize:]) || r.IsZero() || s.IsZero() {
+		return fmt.Errorf("%w: value out of range", ErrInvalidSignature)
+	}
+	if s.IsOverHalfOrder() {
+		return ErrHighS
+	}
+	if !secpecdsa.NewSignature(&r, &s).Verify(digest, key) {
+		return ErrMessageMismatch
+	}
+	return nil
+}
+
+type ed25519Scheme struct{}
+
+func (ed25519Scheme) NewKey() ([]byte, []byte, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return private.Seed(), public, nil
+}
+
+func (ed25519Scheme) PublicKey(private []byte) ([]byte, error) {
+	if len(private) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidPrivateKey, len(private), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(private).Public().(ed25519.PublicKey), nil
+}
+
+func (ed25519Scheme) Sign(private, digest []byte) ([]byte, error) {
+	if len(private) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidPrivateKey, len(private), ed25519.SeedSize)
+	}
+	return ed25519.Sign(ed25519.NewKeyFromSeed(private), digest), nil
+}
+
+func (ed25519Scheme) Verify(public, digest, signature []byte, strict bool) error {
+	if len(public) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidPublicKey, len(public), ed25519.PublicKeySize)
+	}
+	if len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidSignature, len(signature), ed25519.SignatureSize)
+	}
+	if !ed25519.Verify(public, digest, signature) {
+		return ErrMessageMismatch
+	}
+	return nil
+}
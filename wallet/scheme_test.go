@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestSchemesSignAndVerify(t *testing.T) {
+	digest := MessageHash("digest")
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		w := MakeWallet(keyType)
+		scheme, err := SchemeFor(keyType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature, err := w.Sign(digest)
+		if err != nil {
+			t.Fatalf("%s: %v", keyType, err)
+		}
+		if err := scheme.Verify(w.PublicKey, digest, signature, true); err != nil {
+			t.Errorf("%s: %v", keyType, err)
+		}
+		if err := scheme.Verify(w.PublicKey, MessageHash("other"), signature, true); err == nil {
+			t.Errorf("%s: a signature over another digest verified", keyType)
+		}
+		if err := scheme.Verify(MakeWallet(keyType).PublicKey, digest, signature, true); err == nil {
+			t.Errorf("%s: a signature verified under another key", keyType)
+		}
+
+		public, err := scheme.PublicKey(w.PrivateKey)
+		if err != nil || string(public) != string(w.PublicKey) {
+			t.Errorf("%s: the public key does not derive from the secret: %v", keyType, err)
+		}
+	}
+}
+
+func TestKeyTypeNames(t *testing.T) {
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		parsed, err := ParseKeyType(keyType.String())
+		if err != nil || parsed != keyType {
+			t.Errorf("%s parses as %s, %v", keyType, parsed, err)
+		}
+	}
+	if _, err := ParseKeyType("rsa"); !errors.Is(err, ErrUnknownKeyType) {
+		t.Errorf("an unknown name: got %v, want %v", err, ErrUnknownKeyType)
+	}
+	if _, err := SchemeFor(KeyType(42)); !errors.Is(err, ErrUnknownKeyType) {
+		t.Errorf("an unknown type: got %v, want %v", err, ErrUnknownKeyType)
+	}
+}
+
+func TestTypedAddresses(t *testing.T) {
+	p := &params.RegTest
+	for _, keyType := range []KeyType{P256, Secp256k1, Ed25519, Schnorr} {
+		w := MakeWallet(keyType)
+		addr, err := DecodeAddress(string(w.Address(p.AddressVersion)), p)
+		if err != nil {
+			t.Fatalf("%s: %v", keyType, err)
+		}
+		if addr.Key != keyType || !MatchesPublicKey(addr.Hash, w.PublicKey) {
+			t.Errorf("%s address decodes as %+v", keyType, addr)
+		}
+	}
+
+	unknown := append([]byte{p.AddressVersion, 42}, Hash160([]byte("key"))...)
+	unknown = append(unknown, Checksum(unknown)...)
+	if _, err := DecodeAddress(string(Base58Encode(unknown)), p); !errors.Is(err, ErrAddressVersion) {
+		t.Errorf("an unknown key type: got %v, want %v", err, ErrAddressVersion)
+	}
+}
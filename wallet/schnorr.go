@@ -0,0 +1,234 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Schnorr signatures over secp256k1 are R | s, with R a compressed point, and
+// verify when s*G == R + e*P for e = H(R | P | digest).
+const SchnorrSignatureLength = CompressedKeyLength + coordinateSize
+
+var ErrBatchVerify = errors.New("batch signature verification failed")
+
+func taggedHash(tag string, parts ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	hasher := sha256.New()
+	hasher.Write(tagHash[:])
+	hasher.Write(tagHash[:])
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+	return hasher.Sum(nil)
+}
+
+func hashToScalar(tag string, parts ...[]byte) *secp256k1.ModNScalar {
+	var scalar secp256k1.ModNScalar
+	scalar.SetByteSlice(taggedHash(tag, parts...))
+	return &scalar
+}
+
+func randomScalar() (*secp256k1.ModNScalar, error) {
+	var buf [32]byte
+	var scalar secp256k1.ModNScalar
+	for {
+		if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
+			return nil, err
+		}
+		if !scalar.SetByteSlice(buf[:]) && !scalar.IsZero() {
+			return &scalar, nil
+		}
+	}
+}
+
+func parsePoint(encoded []byte) (*secp256k1.JacobianPoint, error) {
+	if len(encoded) != CompressedKeyLength {
+		return nil, fmt.Errorf("%w: %d bytes is not a compressed secp256k1 point", ErrInvalidPublicKey, len(encoded))
+	}
+	key, err := secp256k1.ParsePubKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPublicKey, err)
+	}
+
+	var point secp256k1.JacobianPoint
+	key.AsJacobian(&point)
+	return &point, nil
+}
+
+func isInfinity(point *secp256k1.JacobianPoint) bool {
+	return point.Z.IsZero() || (point.X.IsZero() && point.Y.IsZero())
+}
+
+func encodePoint(point *secp256k1.JacobianPoint) ([]byte, error) {
+	if isInfinity(point) {
+		return nil, fmt.Errorf("%w: point at infinity", ErrInvalidPublicKey)
+	}
+	affine := *point
+	affine.ToAffine()
+	return secp256k1.NewPublicKey(&affine.X, &affine.Y).SerializeCompressed(), nil
+}
+
+func schnorrChallenge(r, public, digest []byte) *secp256k1.ModNScalar {
+	return hashToScalar("golang-blockchain/challenge", r, public, digest)
+}
+
+func parseSchnorrSignature(signature []byte) (*secp256k1.JacobianPoint, *secp256k1.ModNScalar, error) {
+	if len(signature) != SchnorrSignatureLength {
+		return nil, nil, fmt.Errorf("%w: %d bytes, expected %d", ErrInvalidSignature, len(signature), SchnorrSignatureLength)
+	}
+	r, err := parsePoint(signature[:CompressedKeyLength])
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: bad nonce point", ErrInvalidSignature)
+	}
+	var s secp256k1.ModNScalar
+	if s.SetByteSlice(signature[CompressedKeyLength:]) {
+		return nil, nil, fmt.Errorf("%w: value out of range", ErrInvalidSignature)
+	}
+	return r, &s, nil
+}
+
+func signSchnorr(x *secp256k1.ModNScalar, public, digest []byte) ([]byte, error) {
+	k, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	var r secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(k, &r)
+	rBytes, err := encodePoint(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	e := schnorrChallenge(rBytes, public, digest)
+	s := new(secp256k1.ModNScalar).Mul2(e, x).Add(k)
+	sBytes := s.Bytes()
+	return append(rBytes, sBytes[:]...), nil
+}
+
+type schnorrScheme struct {
+	secp256k1Scheme
+}
+
+func (s schnorrScheme) Sign(private, digest []byte) ([]byte, error) {
+	key, err := s.privateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return signSchnorr(&key.Key, key.PubKey().SerializeCompressed(), digest)
+}
+
+func (schnorrScheme) Verify(public, digest, signature []byte, strict bool) error {
+	var batch SchnorrBatch
+	if err := batch.Add(public, digest, signature); err != nil {
+		return err
+	}
+	if batch.Verify() != nil {
+		return ErrMessageMismatch
+	}
+	return nil
+}
+
+type schnorrEntry struct {
+	public, r secp256k1.JacobianPoint
+	s, e      secp256k1.ModNScalar
+}
+
+// A SchnorrBatch checks many signatures with a single multi-scalar equation,
+// sum(a_i*s_i)*G == sum(a_i*R_i) + sum(a_i*e_i*P_i), for random weights a_i.
+type SchnorrBatch struct {
+	entries []schnorrEntry
+}
+
+func (b *SchnorrBatch) Add(public, digest, signature []byte) error {
+	p, err := parsePoint(public)
+	if err != nil {
+		return err
+	}
+	r, s, err := parseSchnorrSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	entry := schnorrEntry{public: *p, r: *r, s: *s}
+	entry.e = *schnorrChallenge(signature[:CompressedKeyLength], public, digest)
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+func (b *SchnorrBatch) Len() int {
+	return len(b.entries)
+}
+
+func (b *SchnorrBatch) Verify() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+
+	var sum secp256k1.ModNScalar
+	var rhs secp256k1.JacobianPoint
+	for i, entry := range b.entries {
+		weight := new(secp256k1.ModNScalar).SetInt(1)
+		if i > 0 {
+			var err error
+			weight, err = randomScalar()
+			if err != nil {
+				return err
+			}
+		}
+		sum.Add(new(secp256k1.ModNScalar).Mul2(weight, &entry.s))
+
+		var weightedR, weightedP secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(weight, &entry.r, &weightedR)
+		secp256k1.ScalarMultNonConst(new(secp256k1.ModNScalar).Mul2(weight, &entry.e), &entry.public, &weightedP)
+		addPoints(&rhs, &weightedR)
+		addPoints(&rhs, &weightedP)
+	}
+
+	var lhs secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&sum, &lhs)
+	if !equalPoints(&lhs, &rhs) {
+		return ErrBatchVerify
+	}
+	return nil
+}
+
+// Failed returns the index of the first signature that does not verify on
+// its own, to report which input broke a batch.
+func (b *SchnorrBatch) Failed() int {
+	for i := range b.entries {
+		single := SchnorrBatch{entries: b.entries[i : i+1]}
+		if single.Verify() != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// addPoints accumulates into sum, treating an unset sum as the point at
+// infinity.
+func addPoints(sum, point *secp256k1.JacobianPoint) {
+	if isInfinity(sum) {
+		sum.Set(point)
+		return
+	}
+	if isInfinity(point) {
+		return
+	}
+	var result secp256k1.JacobianPoint
+	secp256k1.AddNonConst(sum, point, &result)
+	sum.Set(&result)
+}
+
+func equalPoints(a, b *secp256k1.JacobianPoint) bool {
+	if isInfinity(a) || isInfinity(b) {
+		return isInfinity(a) && isInfinity(b)
+	}
+	encodedA, _ := encodePoint(a)
+	encodedB, _ := encodePoint(b)
+	return string(encodedA) == string(encodedB)
+}
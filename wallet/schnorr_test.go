@@ -0,0 +1,118 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+func schnorrKey(t *testing.T) (Scheme, []byte, []byte) {
+	t.Helper()
+	scheme, err := SchemeFor(Schnorr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, public, err := scheme.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheme, private, public
+}
+
+func TestSchnorrSignVerify(t *testing.T) {
+	scheme, private, public := schnorrKey(t)
+	digest := sha256.Sum256([]byte("message"))
+	signature, err := scheme.Sign(private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signature) != SchnorrSignatureLength {
+		t.Fatalf("signature is %d bytes, want %d", len(signature), SchnorrSignatureLength)
+	}
+	if err := scheme.Verify(public, digest[:], signature, true); err != nil {
+		t.Fatal(err)
+	}
+
+	other := sha256.Sum256([]byte("another message"))
+	if err := scheme.Verify(public, other[:], signature, true); !errors.Is(err, ErrMessageMismatch) {
+		t.Errorf("verifying another digest: got %v, want %v", err, ErrMessageMismatch)
+	}
+	_, _, otherKey := schnorrKey(t)
+	if err := scheme.Verify(otherKey, digest[:], signature, true); !errors.Is(err, ErrMessageMismatch) {
+		t.Errorf("verifying with another key: got %v, want %v", err, ErrMessageMismatch)
+	}
+
+	for _, i := range []int{CompressedKeyLength - 1, len(signature) - 1} {
+		tampered := append([]byte{}, signature...)
+		tampered[i] ^= 1
+		if err := scheme.Verify(public, digest[:], tampered, true); err == nil {
+			t.Errorf("signature with byte %d flipped verifies", i)
+		}
+	}
+	if err := scheme.Verify(public, digest[:], signature[:len(signature)-1], true); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("truncated signature: got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+// schnorrBatch signs count digests with fresh keys, signing the wrong
+// digest at index bad.
+func schnorrBatch(t *testing.T, count, bad int) *SchnorrBatch {
+	t.Helper()
+	var batch SchnorrBatch
+	for i := 0; i < count; i++ {
+		scheme, private, public := schnorrKey(t)
+		digest := sha256.Sum256([]byte{byte(i)})
+		signed := digest
+		if i == bad {
+			signed = sha256.Sum256([]byte("something else"))
+		}
+		signature, err := scheme.Sign(private, signed[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := batch.Add(public, digest[:], signature); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &batch
+}
+
+func TestSchnorrBatch(t *testing.T) {
+	batch := schnorrBatch(t, 4, -1)
+	if err := batch.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if failed := batch.Failed(); failed != -1 {
+		t.Fatalf("a valid batch reports signature %d as failed", failed)
+	}
+
+	for bad := 0; bad < 4; bad++ {
+		batch := schnorrBatch(t, 4, bad)
+		if err := batch.Verify(); !errors.Is(err, ErrBatchVerify) {
+			t.Fatalf("batch with signature %d bad: got %v, want %v", bad, err, ErrBatchVerify)
+		}
+		if failed := batch.Failed(); failed != bad {
+			t.Fatalf("batch reports signature %d as failed, want %d", failed, bad)
+		}
+	}
+}
+
+func TestSchnorrBatchRejectsMalformedEntries(t *testing.T) {
+	scheme, private, public := schnorrKey(t)
+	digest := sha256.Sum256([]byte("message"))
+	signature, err := scheme.Sign(private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch SchnorrBatch
+	if err := batch.Add(public, digest[:], signature[1:]); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("adding a malformed signature: got %v, want %v", err, ErrInvalidSignature)
+	}
+	if err := batch.Add(public[1:], digest[:], signature); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Errorf("adding a malformed key: got %v, want %v", err, ErrInvalidPublicKey)
+	}
+	if batch.Len() != 0 {
+		t.Errorf("malformed entries were added to the batch")
+	}
+}
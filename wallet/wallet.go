@@ -1,67 +1,63 @@
 package wallet
 
 import (
-	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"log"
-)
 
-const (
-	checksumLength = 4
-	version        = byte(0x00)
+	"golang-blockchain/params"
 )
 
+const checksumLength = 4
+
 type Wallet struct {
-	PrivateKey ecdsa.PrivateKey
+	Type       KeyType
+	PrivateKey []byte
 	PublicKey  []byte
 }
 
-func (w Wallet) Address() []byte {
-	pubHash := PublicKeyHash(w.PublicKey)
-
-	versionedHash := append([]byte{version}, pubHash...)
-	checksum := Checksum(versionedHash)
-
-	fullHash := append(versionedHash, checksum...)
-	address := Base58Encode(fullHash)
-
-	return address
+func (w Wallet) Address(version byte) []byte {
+	addr := Address{Type: PubKeyHashAddress, Key: w.Type, Hash: PublicKeyHash(w.PublicKey)}
+	return []byte(addr.encode(version))
 }
 
-func ValidateAddress(address string) bool {
-	publicKey := Base58Decode([]byte(address))
-	version := publicKey[0]
-	pubHash := publicKey[1 : len(publicKey)-checksumLength]
-	currentChecksum := publicKey[len(publicKey)-checksumLength:]
-	targetChecksum := Checksum(append([]byte{version}, pubHash...))
-	return bytes.Equal(currentChecksum, targetChecksum)
+func (w Wallet) Sign(digest []byte) ([]byte, error) {
+	if w.PrivateKey == nil {
+		return nil, ErrWalletLocked
+	}
+	scheme, err := SchemeFor(w.Type)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.Sign(w.PrivateKey, digest)
 }
 
-func NewKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
+func ValidateAddress(address string, p *params.Params) error {
+	_, err := DecodeAddress(address, p)
+	return err
+}
 
-	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+func NewKeyPair(keyType KeyType) ([]byte, []byte) {
+	scheme, err := SchemeFor(keyType)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	pub := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
-	return *private, pub
+	private, public, err := scheme.NewKey()
+	if err != nil {
+		log.Panic(err)
+	}
+	return private, public
 }
 
-func MakeWallet() *Wallet {
-	private, public := NewKeyPair()
-	wallet := Wallet{private, public}
+func MakeWallet(keyType KeyType) *Wallet {
+	private, public := NewKeyPair(keyType)
+	wallet := Wallet{keyType, private, public}
 
 	return &wallet
 }
 
 func PublicKeyHash(publicKey []byte) []byte {
-	hash := sha256.Sum256(publicKey)
-	return hash[:]
+	return Hash160(publicKey)
 }
 
 func Checksum(payload []byte) []byte {
@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	walletFormatVersion = 6
+	walletBackups       = 3
+	walletHeaderLength  = 8 + 2 + 4
+)
+
+var walletMagic = []byte("GBWALLET")
+
+var ErrCorruptedWallet = errors.New("wallet file is corrupted")
+
+func encodeWalletFile(version int, payload []byte) []byte {
+	var content bytes.Buffer
+	content.Write(walletMagic)
+	binary.Write(&content, binary.BigEndian, uint16(version))
+	binary.Write(&content, binary.BigEndian, uint32(len(payload)))
+	content.Write(payload)
+	content.Write(Checksum(payload))
+	return content.Bytes()
+}
+
+func hasWalletHeader(content []byte) bool {
+	return bytes.HasPrefix(content, walletMagic)
+}
+
+func decodeWalletFile(path string, content []byte) (int, []byte, error) {
+	if len(content) < walletHeaderLength+checksumLength {
+		return 0, nil, fmt.Errorf("%w: %s is truncated", ErrCorruptedWallet, path)
+	}
+
+	version := int(binary.BigEndian.Uint16(content[8:10]))
+	if version > walletFormatVersion {
+		return 0, nil, fmt.Errorf("%s has format version %d, this build only reads up to %d", path, version, walletFormatVersion)
+	}
+
+	length := int(binary.BigEndian.Uint32(content[10:14]))
+	if len(content) != walletHeaderLength+length+checksumLength {
+		return 0, nil, fmt.Errorf("%w: %s should hold %d bytes of wallet data but holds %d",
+			ErrCorruptedWallet, path, length, len(content)-walletHeaderLength-checksumLength)
+	}
+
+	payload := content[walletHeaderLength : walletHeaderLength+length]
+	if !bytes.Equal(Checksum(payload), content[walletHeaderLength+length:]) {
+		return 0, nil, fmt.Errorf("%w: %s fails its checksum, restore it from %s.1", ErrCorruptedWallet, path, path)
+	}
+	return version, payload, nil
+}
+
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Chmod(perm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func rotateBackups(path string, count int) error {
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := count - 1; i > 0; i-- {
+		err = os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(path+".1", current, 0600)
+}
+
+// migrate brings a file written by an older build up to the current format,
+// one version step at a time.
+func (ws *Wallets) migrate(from int) error {
+	if from < 2 {
+		ws.migrateMetadata()
+	}
+	if from < 4 {
+		err := ws.migrateAddressHashes()
+		if err != nil {
+			return err
+		}
+	}
+	if from < 5 {
+		err := ws.migratePublicKeys()
+		if err != nil {
+			return err
+		}
+	}
+	if from < 6 {
+		err := ws.migrateKeyLayout()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Before version 4 every address was a bare SHA-256 of its public key.
+// Coins stay locked to those hashes, so the addresses are kept, but each one
+// has to still belong to the key stored with it.
+func (ws *Wallets) migrateAddressHashes() error {
+	keys := make(map[string][]byte)
+	for address, wallet := range ws.Wallets {
+		keys[address] = wallet.PublicKey
+	}
+	for address, publicKey := range ws.WatchOnly {
+		keys[address] = publicKey
+	}
+
+	for address, publicKey := range keys {
+		addr, err := DecodeAddress(address, ws.params)
+		if err != nil {
+			return fmt.Errorf("%w: address %s: %s", ErrCorruptedWallet, address, err)
+		}
+		hash := sha256.Sum256(publicKey)
+		if len(addr.Hash) != sha256.Size || (len(publicKey) > 0 && !bytes.Equal(addr.Hash, hash[:])) {
+			return fmt.Errorf("%w: address %s is not the SHA-256 hash of its key", ErrCorruptedWallet, address)
+		}
+	}
+	return nil
+}
+
+// Before version 5 public keys were stored as X.Bytes() || Y.Bytes(). The
+// addresses hash that encoding, so it stays as stored, but it has to parse
+// to a point on the curve and, where the secret is at hand, to its key.
+func (ws *Wallets) migratePublicKeys() error {
+	for address, wallet := range ws.Wallets {
+		if wallet.Type != P256 {
+			continue
+		}
+		key, err := ParseLegacyPublicKey(wallet.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: address %s: %s", ErrCorruptedWallet, address, err)
+		}
+		if wallet.PrivateKey == nil {
+			continue
+		}
+		publicKey, err := p256Scheme{}.PublicKey(wallet.PrivateKey)
+		if err != nil || !bytes.Equal(publicKey, EncodePublicKey(key)) {
+			return fmt.Errorf("%w: the public key of %s does not belong to its private key", ErrCorruptedWallet, address)
+		}
+	}
+	for address, publicKey := range ws.WatchOnly {
+		if len(publicKey) == 0 {
+			continue
+		}
+		_, err := ParseLegacyPublicKey(publicKey)
+		if err != nil {
+			return fmt.Errorf("%w: watch-only address %s: %s", ErrCorruptedWallet, address, err)
+		}
+	}
+	return nil
+}
+
+// Before version 6 every key was P256 and had no type stored with it, and
+// its secret was D.Bytes(), which drops leading zero bytes. Secrets are
+// padded to their full width, sealed ones when the wallet is unlocked.
+func (ws *Wallets) migrateKeyLayout() error {
+	for address, wallet := range ws.Wallets {
+		addr, err := DecodeAddress(address, ws.params)
+		if err != nil {
+			return fmt.Errorf("%w: address %s: %s", ErrCorruptedWallet, address, err)
+		}
+		if wallet.Type != P256 || addr.Key != P256 {
+			return fmt.Errorf("%w: address %s is not a P256 key", ErrCorruptedWallet, address)
+		}
+		wallet.PrivateKey = padPrivateKey(wallet.Type, wallet.PrivateKey)
+	}
+	return nil
+}
+
+func padPrivateKey(keyType KeyType, private []byte) []byte {
+	if keyType != P256 || private == nil || len(private) >= privateKeyLength {
+		return private
+	}
+	padded := make([]byte, privateKeyLength-len(private), privateKeyLength)
+	return append(padded, private...)
+}
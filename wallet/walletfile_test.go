@@ -0,0 +1,365 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestSaveFileKeepsBackups(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	var saved []string
+	for i := 0; i < walletBackups+2; i++ {
+		wallets.AddWallet(P256)
+		if err := wallets.SaveFile(); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(p.WalletPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, string(content))
+	}
+
+	for i := 1; i <= walletBackups; i++ {
+		backup, err := os.ReadFile(fmt.Sprintf("%s.%d", p.WalletPath(), i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(backup) != saved[len(saved)-1-i] {
+			t.Errorf("backup %d is not the file saved %d times ago", i, i)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", p.WalletPath(), walletBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("more than %d backups are kept", walletBackups)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(p.WalletPath()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp") {
+			t.Errorf("%s was left behind", entry.Name())
+		}
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().IsRegular() && info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v", entry.Name(), info.Mode().Perm())
+		}
+	}
+}
+
+func TestLoadFileDetectsCorruption(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	wallets.AddWallet(P256)
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(p.WalletPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte{}, content...)
+	flipped[walletHeaderLength+len(flipped[walletHeaderLength:])/2] ^= 0x01
+	futureVersion := append([]byte{}, content...)
+	futureVersion[9]++
+
+	tests := []struct {
+		name    string
+		content []byte
+		corrupt bool
+	}{
+		{"a flipped bit", flipped, true},
+		{"a truncated file", content[:len(content)-10], true},
+		{"a cut off header", content[:walletHeaderLength-2], true},
+		{"garbage without a header", []byte("not a wallet"), true},
+		{"a newer format", futureVersion, false},
+	}
+	for _, test := range tests {
+		if err := os.WriteFile(p.WalletPath(), test.content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := CreateWallets(p)
+		if err == nil {
+			t.Errorf("%s: the wallet loaded", test.name)
+			continue
+		}
+		if corrupt := errors.Is(err, ErrCorruptedWallet); corrupt != test.corrupt {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+}
+
+func TestBackupRestoresACorruptedWallet(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	address := wallets.AddWallet(P256)
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	wallets.AddWallet(P256)
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.WalletPath(), []byte("GBWALLET broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading the broken file: got %v, want %v", err, ErrCorruptedWallet)
+	}
+
+	backup, err := os.ReadFile(p.WalletPath() + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.WalletPath(), backup, 0600); err != nil {
+		t.Fatal(err)
+	}
+	restored := loadWallets(t, p)
+	if _, ok := restored.Wallets[address]; !ok || len(restored.Wallets) != 1 {
+		t.Fatalf("the backup holds %v", restored.GetAllAddresses())
+	}
+}
+
+// writeWalletFile stores data the way a build at the given format version
+// would have.
+func writeWalletFile(t *testing.T, p *params.Params, version int, data walletData) {
+	t.Helper()
+	data.Version = version
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.WalletPath(), encodeWalletFile(version, payload.Bytes()), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// legacyWallet makes a key the way the first builds did: the address is a
+// SHA-256 of X||Y.
+func legacyWallet(t *testing.T, p *params.Params) (string, SerializableWallet) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := append(key.X.Bytes(), key.Y.Bytes()...)
+	hash := sha256.Sum256(publicKey)
+	addr := Address{Type: PubKeyHashAddress, Hash: hash[:]}
+	return addr.Encode(p), SerializableWallet{PrivateKey: key.D.Bytes(), PublicKey: publicKey}
+}
+
+func loadTestWallets(p *params.Params) (*Wallets, error) {
+	ws := &Wallets{params: p}
+	return ws, ws.LoadFile()
+}
+
+func TestMigrateKeepsSHA256Addresses(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, sw := legacyWallet(t, p)
+	watched, watchedKey := legacyWallet(t, p)
+	writeWalletFile(t, p, 3, walletData{
+		Wallets:   map[string]SerializableWallet{address: sw},
+		WatchOnly: map[string][]byte{watched: watchedKey.PublicKey},
+	})
+
+	ws, err := loadTestWallets(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ws.Wallets[address]; !ok {
+		t.Fatalf("address %s was dropped by the migration", address)
+	}
+	if !ws.IsWatchOnly(watched) {
+		t.Fatalf("watch-only address %s was dropped by the migration", watched)
+	}
+	if hash, err := AddressPublicKeyHash(address, p); err != nil || !MatchesPublicKey(hash, ws.Wallets[address].PublicKey) {
+		t.Fatal("the migrated address no longer matches its key")
+	}
+
+	// Once saved the file is current and loads without migrating again.
+	if err := ws.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(p.WalletPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, _, err := decodeWalletFile(p.WalletPath(), content); err != nil || version != walletFormatVersion {
+		t.Fatalf("saved file has version %d (%v), want %d", version, err, walletFormatVersion)
+	}
+	if _, err := loadTestWallets(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateRejectsMismatchedAddress(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, _ := legacyWallet(t, p)
+	_, other := legacyWallet(t, p)
+	writeWalletFile(t, p, 3, walletData{Wallets: map[string]SerializableWallet{address: other}})
+
+	if _, err := loadTestWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading an address stored with another key: got %v, want %v", err, ErrCorruptedWallet)
+	}
+}
+
+func TestMigrateRejectsHash160AddressInOldFile(t *testing.T) {
+	_, p := newTestWallets(t)
+	w := MakeWallet(P256)
+	address := string(w.Address(p.AddressVersion))
+	writeWalletFile(t, p, 3, walletData{Wallets: map[string]SerializableWallet{address: w.ToSerializable()}})
+
+	if _, err := loadTestWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading a HASH160 address from a version 3 file: got %v, want %v", err, ErrCorruptedWallet)
+	}
+}
+
+func TestMigrateKeepsLegacyPublicKeys(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, sw := legacyWallet(t, p)
+	writeWalletFile(t, p, 4, walletData{Wallets: map[string]SerializableWallet{address: sw}})
+
+	ws, err := loadTestWallets(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ws.Wallets[address].PublicKey, sw.PublicKey) {
+		t.Fatal("the migration re-encoded a key its address was hashed from")
+	}
+
+	// The key still exports and imports back in the encoding the address
+	// was hashed from.
+	exported, err := ws.ExportKey(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := DecodePrivateKey(exported, p.PrivateKeyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := AddressPublicKeyHash(address, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(imported.PublicKey, sw.PublicKey) || !MatchesPublicKey(hash, imported.PublicKey) {
+		t.Fatalf("re-imported key %x does not match address %s", imported.PublicKey, address)
+	}
+}
+
+func TestMigrateRejectsForeignPublicKey(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, sw := legacyWallet(t, p)
+	_, other := legacyWallet(t, p)
+	sw.PrivateKey = other.PrivateKey
+	writeWalletFile(t, p, 4, walletData{Wallets: map[string]SerializableWallet{address: sw}})
+
+	if _, err := loadTestWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading a key pair that does not match: got %v, want %v", err, ErrCorruptedWallet)
+	}
+}
+
+func TestMigrateRejectsPublicKeyOffTheCurve(t *testing.T) {
+	_, p := newTestWallets(t)
+	watched, sw := legacyWallet(t, p)
+	offCurve := append([]byte{}, sw.PublicKey...)
+	offCurve[len(offCurve)-1] ^= 1
+	writeWalletFile(t, p, 4, walletData{WatchOnly: map[string][]byte{watched: offCurve}})
+
+	if _, err := loadTestWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading a watch-only key off the curve: got %v, want %v", err, ErrCorruptedWallet)
+	}
+}
+
+// shortLegacyWallet makes a legacy key whose D.Bytes() lost a leading zero.
+func shortLegacyWallet(t *testing.T, p *params.Params) (string, SerializableWallet) {
+	t.Helper()
+	for {
+		address, sw := legacyWallet(t, p)
+		if len(sw.PrivateKey) < privateKeyLength {
+			return address, sw
+		}
+	}
+}
+
+func TestMigratePadsShortSecrets(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, sw := shortLegacyWallet(t, p)
+	writeWalletFile(t, p, 5, walletData{Wallets: map[string]SerializableWallet{address: sw}})
+
+	ws, err := loadTestWallets(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := ws.Wallets[address]
+	if w.Type != P256 || len(w.PrivateKey) != privateKeyLength {
+		t.Fatalf("migrated key has type %s and a %d byte secret", w.Type, len(w.PrivateKey))
+	}
+	if !bytes.Equal(w.PrivateKey[privateKeyLength-len(sw.PrivateKey):], sw.PrivateKey) {
+		t.Fatal("padding changed the secret")
+	}
+	exported, err := ws.ExportKey(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := DecodePrivateKey(exported, p.PrivateKeyVersion)
+	if err != nil || !bytes.Equal(imported.PrivateKey, w.PrivateKey) {
+		t.Fatalf("exported key does not round trip: %v", err)
+	}
+}
+
+func TestMigratePadsSealedSecretsOnUnlock(t *testing.T) {
+	_, p := newTestWallets(t)
+	address, sw := shortLegacyWallet(t, p)
+	encryption, key, err := newEncryption("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := seal(key, sw.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeWalletFile(t, p, 5, walletData{
+		Encryption: encryption,
+		Wallets:    map[string]SerializableWallet{address: {PrivateKey: sealed, PublicKey: sw.PublicKey}},
+	})
+
+	ws, err := loadTestWallets(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Unlock("pw"); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ws.Wallets[address].PrivateKey); got != privateKeyLength {
+		t.Fatalf("unlocked secret is %d bytes, want %d", got, privateKeyLength)
+	}
+	if _, err := ws.Wallets[address].Sign(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateRejectsTypedKeyInOldFile(t *testing.T) {
+	_, p := newTestWallets(t)
+	w := MakeWallet(Secp256k1)
+	address := string(w.Address(p.AddressVersion))
+	writeWalletFile(t, p, 5, walletData{Wallets: map[string]SerializableWallet{address: w.ToSerializable()}})
+
+	if _, err := loadTestWallets(p); !errors.Is(err, ErrCorruptedWallet) {
+		t.Fatalf("loading a secp256k1 key from a version 5 file: got %v, want %v", err, ErrCorruptedWallet)
+	}
+}
@@ -2,57 +2,78 @@ package wallet
 
 import (
 	"bytes"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"encoding/gob"
 	"fmt"
-	"math/big"
 	"os"
-)
 
-const walletFile = "./tmp/wallets.data"
+	"golang-blockchain/params"
+)
 
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets    map[string]*Wallet
+	WatchOnly  map[string][]byte
+	Metadata   map[string]*AddressInfo
+	Nonces     map[string][]byte
+	params     *params.Params
+	encryption *Encryption
+	key        []byte
+	sealedKeys map[string][]byte
+	hd         *HDChain
+	sealedSeed []byte
+
+	sealedMnemonic []byte
+}
+
+type walletData struct {
+	Version    int
+	Encryption *Encryption
+	Wallets    map[string]SerializableWallet
+	HD         *HDChain
+	WatchOnly  map[string][]byte
+	Metadata   map[string]*AddressInfo
+	Nonces     map[string][]byte
 }
 
 type SerializableWallet struct {
+	Type       KeyType
 	PrivateKey []byte
 	PublicKey  []byte
 }
 
 func (w *Wallet) ToSerializable() SerializableWallet {
 	return SerializableWallet{
-		PrivateKey: w.PrivateKey.D.Bytes(),
+		Type:       w.Type,
+		PrivateKey: w.PrivateKey,
 		PublicKey:  w.PublicKey,
 	}
 }
 
 func FromSerializable(sw SerializableWallet) Wallet {
-	priv := new(ecdsa.PrivateKey)
-	priv.Curve = elliptic.P256()
-	priv.D = new(big.Int).SetBytes(sw.PrivateKey)
-	priv.PublicKey.X, priv.PublicKey.Y = priv.Curve.ScalarBaseMult(sw.PrivateKey)
 	return Wallet{
-		PrivateKey: *priv,
+		Type:       sw.Type,
+		PrivateKey: sw.PrivateKey,
 		PublicKey:  sw.PublicKey,
 	}
 }
 
-func CreateWallets() (*Wallets, error) {
-	wallets := Wallets{}
+func CreateWallets(p *params.Params) (*Wallets, error) {
+	wallets := Wallets{params: p}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.WatchOnly = make(map[string][]byte)
+	wallets.Metadata = make(map[string]*AddressInfo)
+	wallets.Nonces = make(map[string][]byte)
 
 	err := wallets.LoadFile()
 
 	return &wallets, err
 }
 
-func (ws *Wallets) AddWallet() string {
-	wallet := MakeWallet()
-	address := fmt.Sprintf("%s", wallet.Address())
+func (ws *Wallets) AddWallet(keyType KeyType) string {
+	wallet := MakeWallet(keyType)
+	address := fmt.Sprintf("%s", wallet.Address(ws.params.AddressVersion))
 
 	ws.Wallets[address] = wallet
+	ws.addInfo(address, PurposeReceive)
 
 	return address
 }
@@ -72,51 +93,126 @@ func (ws Wallets) GetWallet(address string) Wallet {
 }
 
 func (ws *Wallets) SaveFile() error {
-	serializableWallets := make(map[string]SerializableWallet)
+	data := walletData{
+		Version:    walletFormatVersion,
+		Encryption: ws.encryption,
+		Wallets:    make(map[string]SerializableWallet),
+		WatchOnly:  ws.WatchOnly,
+		Metadata:   ws.Metadata,
+		Nonces:     ws.Nonces,
+	}
 	for address, wallet := range ws.Wallets {
-		serializableWallets[address] = wallet.ToSerializable()
+		if ws.encryption == nil {
+			data.Wallets[address] = wallet.ToSerializable()
+			continue
+		}
+
+		sealed, err := ws.sealPrivateKey(address, wallet)
+		if err != nil {
+			return err
+		}
+		data.Wallets[address] = SerializableWallet{Type: wallet.Type, PrivateKey: sealed, PublicKey: wallet.PublicKey}
 	}
 
-	var content bytes.Buffer
-	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(serializableWallets)
-	if err != nil {
-		return err
+	if ws.hd != nil {
+		hd := *ws.hd
+		if ws.encryption != nil {
+			sealed, err := ws.sealSecret(ws.hd.Seed, ws.sealedSeed)
+			if err != nil {
+				return err
+			}
+			hd.Seed = sealed
+			if ws.hd.Mnemonic != nil || ws.sealedMnemonic != nil {
+				hd.Mnemonic, err = ws.sealSecret(ws.hd.Mnemonic, ws.sealedMnemonic)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		data.HD = &hd
 	}
 
-	file, err := os.Create(walletFile)
+	var content bytes.Buffer
+	encoder := gob.NewEncoder(&content)
+	err := encoder.Encode(data)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	_, err = file.Write(content.Bytes())
+	err = rotateBackups(ws.params.WalletPath(), walletBackups)
 	if err != nil {
 		return err
 	}
-
-	return file.Sync()
+	return writeFileAtomic(ws.params.WalletPath(), encodeWalletFile(walletFormatVersion, content.Bytes()), 0600)
 }
 
 func (ws *Wallets) LoadFile() error {
-	file, err := os.Open(walletFile)
+	content, err := os.ReadFile(ws.params.WalletPath())
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	var serializableWallets map[string]SerializableWallet
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&serializableWallets)
-	if err != nil {
-		return err
+	var data walletData
+	if hasWalletHeader(content) {
+		var payload []byte
+		_, payload, err = decodeWalletFile(ws.params.WalletPath(), content)
+		if err != nil {
+			return err
+		}
+		err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&data)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCorruptedWallet, err)
+		}
+	} else {
+		err = gob.NewDecoder(bytes.NewReader(content)).Decode(&data)
+		if err != nil {
+			// Files written before encryption support hold a bare map
+			data = walletData{}
+			err = gob.NewDecoder(bytes.NewReader(content)).Decode(&data.Wallets)
+			if err != nil {
+				return fmt.Errorf("%w: %s is neither a current nor a legacy wallet file", ErrCorruptedWallet, ws.params.WalletPath())
+			}
+		}
 	}
 
 	ws.Wallets = make(map[string]*Wallet)
-	for address, sw := range serializableWallets {
+	ws.WatchOnly = data.WatchOnly
+	if ws.WatchOnly == nil {
+		ws.WatchOnly = make(map[string][]byte)
+	}
+	ws.Metadata = data.Metadata
+	if ws.Metadata == nil {
+		ws.Metadata = make(map[string]*AddressInfo)
+	}
+	ws.Nonces = data.Nonces
+	if ws.Nonces == nil {
+		ws.Nonces = make(map[string][]byte)
+	}
+	ws.encryption = data.Encryption
+	ws.key = nil
+	ws.sealedKeys = make(map[string][]byte)
+	ws.hd = data.HD
+	ws.sealedSeed = nil
+	ws.sealedMnemonic = nil
+	if ws.hd != nil && ws.encryption != nil {
+		ws.sealedSeed, ws.sealedMnemonic = ws.hd.Seed, ws.hd.Mnemonic
+		ws.hd.Seed, ws.hd.Mnemonic = nil, nil
+	}
+	for address, sw := range data.Wallets {
+		if ws.encryption != nil {
+			ws.sealedKeys[address] = sw.PrivateKey
+			ws.Wallets[address] = &Wallet{Type: sw.Type, PublicKey: sw.PublicKey}
+			continue
+		}
 		wallet := FromSerializable(sw)
 		ws.Wallets[address] = &wallet
 	}
 
+	if data.Version < walletFormatVersion {
+		err = ws.migrate(data.Version)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+
+	"golang-blockchain/params"
+)
+
+func AddressPublicKeyHash(address string, p *params.Params) ([]byte, error) {
+	addr, err := DecodeAddress(address, p)
+	if err != nil {
+		return nil, err
+	}
+	return addr.Hash, nil
+}
+
+func (ws *Wallets) AddWatchOnly(address string, publicKey []byte) error {
+	if _, ok := ws.Wallets[address]; ok {
+		return fmt.Errorf("address %s already has its private key in the wallet", address)
+	}
+	hash, err := AddressPublicKeyHash(address, ws.params)
+	if err != nil {
+		return err
+	}
+	if publicKey != nil && !MatchesPublicKey(hash, publicKey) {
+		return fmt.Errorf("public key does not belong to address %s", address)
+	}
+	ws.WatchOnly[address] = publicKey
+	ws.addInfo(address, PurposeWatchOnly)
+	return nil
+}
+
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	_, ok := ws.WatchOnly[address]
+	return ok
+}
+
+func (ws *Wallets) WatchOnlyPublicKey(address string) []byte {
+	publicKey := ws.WatchOnly[address]
+	if len(publicKey) == 0 {
+		return nil
+	}
+	return publicKey
+}
+
+func (ws *Wallets) GetWatchOnlyAddresses() []string {
+	var addresses []string
+	for address := range ws.WatchOnly {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang-blockchain/params"
+)
+
+func TestWatchOnlyAddresses(t *testing.T) {
+	wallets, p := newTestWallets(t)
+	own := wallets.AddWallet(P256)
+	watched := MakeWallet(P256)
+	withKey := string(watched.Address(p.AddressVersion))
+	withoutKey := string(MakeWallet(P256).Address(p.AddressVersion))
+
+	if err := wallets.AddWatchOnly(own, nil); err == nil {
+		t.Error("watched an address whose private key the wallet holds")
+	}
+	if err := wallets.AddWatchOnly(withKey, MakeWallet(P256).PublicKey); err == nil {
+		t.Error("watched an address with a public key of another address")
+	}
+	mainnet := string(watched.Address(params.MainNet.AddressVersion))
+	if err := wallets.AddWatchOnly(mainnet, nil); !errors.Is(err, ErrAddressNetwork) {
+		t.Errorf("watching a mainnet address on regtest: got %v, want %v", err, ErrAddressNetwork)
+	}
+	if err := wallets.AddWatchOnly(withKey, watched.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.AddWatchOnly(withoutKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := loadWallets(t, p)
+	if !loaded.IsWatchOnly(withKey) || !loaded.IsWatchOnly(withoutKey) || loaded.IsWatchOnly(own) {
+		t.Fatalf("watch-only addresses after a reload: %v", loaded.GetWatchOnlyAddresses())
+	}
+	if !bytes.Equal(loaded.WatchOnlyPublicKey(withKey), watched.PublicKey) {
+		t.Error("the watched public key was not kept")
+	}
+	if loaded.WatchOnlyPublicKey(withoutKey) != nil {
+		t.Error("an address watched without a public key has one")
+	}
+	if _, ok := loaded.Wallets[withKey]; ok {
+		t.Error("a watch-only address is listed with the spendable keys")
+	}
+
+	if got, err := AddressPublicKeyHash(withKey, p); err != nil || !bytes.Equal(got, PublicKeyHash(watched.PublicKey)) {
+		t.Errorf("AddressPublicKeyHash() = %x, %v, want %x", got, err, PublicKeyHash(watched.PublicKey))
+	}
+}